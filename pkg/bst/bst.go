@@ -1,15 +1,22 @@
 package bst
 
 import (
+	"bytes"
+	"fmt"
 	"sync"
 
 	errors "github.com/imariom/nexosdb/pkg/errors"
+	"github.com/imariom/nexosdb/pkg/iterator"
 	kv "github.com/imariom/nexosdb/pkg/kvpair"
 )
 
 // node represents a single node in the binary search tree that
 // contains data as a key/value pair.
 type node struct {
+	// key is the key of data, kept in the node so comparisons don't have
+	// to copy it out of the key/value pair.
+	key []byte
+
 	// data is a pointer to where the key/value pair is stored.
 	data *kv.KVPair
 
@@ -18,9 +25,16 @@ type node struct {
 
 	// right is the pointer to the right node.
 	right *node
+
+	// height is the height of the subtree rooted at this node.
+	height int
+
+	// count is the number of nodes in the subtree rooted at this node.
+	count int
 }
 
-// BST represents the binary search tree.
+// BST represents the binary search tree. It is an AVL tree ordered by key,
+// so the height of the tree stays logarithmic in the number of keys.
 type BST struct {
 	// root is the root node of the tree.
 	root *node
@@ -28,31 +42,55 @@ type BST struct {
 	// mu is the read and write mutex used to synchronize
 	// ready and write operations in the BST tree.
 	mu sync.RWMutex
+
+	// len is the number of keys in the tree.
+	len int
+
+	// size is the number of key and value bytes held by the tree.
+	size int64
+
+	// MaxKeySize is the maximum key length accepted by Insert.
+	// Zero means kvpair.MaxKeySize.
+	MaxKeySize int
+
+	// MaxValueSize is the maximum value length accepted by Insert.
+	// Zero means kvpair.MaxValueSize.
+	MaxValueSize int
 }
 
-// Insert inserts a new key/value pair in the tree or updates it if it exists
-// and is not expired.
+// Insert inserts a new key/value pair in the tree or replaces the current pair
+// of the key if it already exists.
 func (bst *BST) Insert(pair *kv.KVPair) error {
-	bst.mu.Lock()
-	defer bst.mu.Unlock()
+	if err := pair.CheckSize(bst.MaxKeySize, bst.MaxValueSize); err != nil {
+		return err
+	}
 
 	p, err := pair.Clone()
 	if err != nil {
 		return err
-	} else if bst.root == nil {
-		bst.root = &node{
-			data: p,
-		}
-		return nil
 	}
-	return inserNode(bst.root, p)
+
+	bst.mu.Lock()
+	defer bst.mu.Unlock()
+	bst.put(p)
+	return nil
+}
+
+// Put inserts or updates a key/value pair. It is the same as Insert.
+func (bst *BST) Put(pair *kv.KVPair) error {
+	return bst.Insert(pair)
 }
 
 // Get return a deep copy
 func (bst *BST) Get(key []byte) (*kv.KVPair, error) {
-	bst.mu.Lock()
-	defer bst.mu.Unlock()
-	return getNode(bst.root, key)
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+
+	n := bst.find(key)
+	if n == nil {
+		return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: errors.ErrKeyNotFound}
+	}
+	return n.pair()
 }
 
 // InOrder traverses the tree in-order (left, root, right).
@@ -61,7 +99,10 @@ func (bst *BST) InOrder() []*kv.KVPair {
 	defer bst.mu.RUnlock()
 
 	var result []*kv.KVPair
-	inOrderTraversal(bst.root, &result)
+	bst.walk(func(n *node) {
+		kv, _ := n.data.Clone()
+		result = append(result, kv)
+	})
 
 	return result
 }
@@ -70,151 +111,401 @@ func (bst *BST) InOrder() []*kv.KVPair {
 func (bst *BST) Search(key []byte) bool {
 	bst.mu.RLock()
 	defer bst.mu.RUnlock()
-	return searchNode(bst.root, key)
+	n := bst.find(key)
+	return n != nil && !n.data.IsTombstone()
 }
 
-// Delete removes a key value pair from the tree if it exists.
+// Delete marks the key as deleted by recording a tombstone for it. The
+// tombstone is recorded even when the key is not in the tree, so that it
+// shadows older versions of the key stored elsewhere.
 func (bst *BST) Delete(key []byte) error {
-	// bst.mu.Lock()
-	// defer bst.mu.Unlock()
-	// bst.root = deleteNode(bst.root, key)
+	if err := kv.CheckSize(key, nil, bst.MaxKeySize, bst.MaxValueSize); err != nil {
+		return err
+	}
+
+	bst.mu.Lock()
+	defer bst.mu.Unlock()
+	bst.put(kv.NewTombstone(append([]byte(nil), key...)))
 	return nil
 }
 
-// inserNode inserts or updates a key/value pair in the tree.
-// If the key/value pair exists and is expired it will return an error
-func inserNode(n *node, p *kv.KVPair) error {
-	pKey, _ := p.HashedKey()
-	nKey, _ := n.data.HashedKey()
+// Remove removes a key value pair from the tree if it exists, without
+// leaving a tombstone behind. It is meant for using the tree as an ordered
+// map; a memtable must use Delete so older versions of the key stay hidden.
+func (bst *BST) Remove(key []byte) error {
+	bst.mu.Lock()
+	defer bst.mu.Unlock()
 
-	if pKey < nKey {
-		// insert a new node in the left of the current node
-		if n.left == nil {
-			n.left = &node{
-				data: p,
-			}
-			return nil
+	// Walk down to the node to remove, remembering the path taken.
+	var path []*node
+	link := &bst.root
+	n := *link
+	for n != nil {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			break
 		}
-		return inserNode(n.left, p)
-	} else if pKey > nKey {
-		// insert a new node in the right of the current node
-		if n.right == nil {
-			n.right = &node{
-				data: p,
-			}
-			return nil
+
+		path = append(path, n)
+		if cmp < 0 {
+			link = &n.left
+		} else {
+			link = &n.right
 		}
-		return inserNode(n.right, p)
+		n = *link
 	}
-	// ensure to update the current node if it already exists
-	return updateNode(n, p)
-}
-
-// updateNode updates the node with the key/value pair passed.
-func updateNode(n *node, p *kv.KVPair) error {
 	if n == nil {
-		return errors.ErrNodeIsNil
+		return nil
 	}
 
-	pkey, _ := p.HashedKey()
-	nKey, _ := n.data.HashedKey()
+	bst.len--
+	bst.size -= int64(n.data.Size())
 
-	if pkey == nKey {
-		return n.data.UpdateWith(p)
-	} else if pkey < nKey {
-		return updateNode(n.left, p)
+	if n.left == nil {
+		*link = n.right
+	} else if n.right == nil {
+		*link = n.left
 	} else {
-		return updateNode(n.right, p)
+		// node has two children, replace it with its in-order successor,
+		// the minimum of its right subtree.
+		var succPath []*node
+		succLink := &n.right
+		succ := n.right
+		for succ.left != nil {
+			succPath = append(succPath, succ)
+			succLink = &succ.left
+			succ = succ.left
+		}
+
+		*succLink = succ.right
+		succ.left, succ.right = n.left, n.right
+		*link = succ
+
+		path = append(path, succ)
+		path = append(path, succPath...)
 	}
+
+	bst.rebalance(path)
+	return nil
 }
 
-// getNode returns the KVPair from the tree identified by the key k.
-func getNode(n *node, k []byte) (*kv.KVPair, error) {
-	if n == nil {
-		return nil, errors.ErrKeyNotFound
+// NewIterator returns an iterator over a snapshot of the non expired
+// key/value pairs of the tree, in key order. Pairs are never modified once in
+// the tree, so the snapshot shares them instead of copying them.
+func (bst *BST) NewIterator() iterator.Iterator {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+
+	keys := make([][]byte, 0, bst.len)
+	pairs := make([]*kv.KVPair, 0, bst.len)
+	bst.walk(func(n *node) {
+		keys = append(keys, n.key)
+		pairs = append(pairs, n.data)
+	})
+
+	return iterator.NewSliceIterator(keys, pairs)
+}
+
+// ApproximateSize returns the number of key and value bytes held by the tree.
+func (bst *BST) ApproximateSize() int64 {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+	return bst.size
+}
+
+// Len returns the number of keys in the tree, including deleted keys.
+func (bst *BST) Len() int {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+	return bst.len
+}
+
+// Rank returns the number of keys in the tree that are smaller than key.
+// Like Len, it counts expired keys and deleted keys, which InOrder and
+// NewIterator skip.
+func (bst *BST) Rank(key []byte) int {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+	return bst.rank(key)
+}
+
+// Select returns a deep copy of the key/value pair with the given rank, that
+// is the i-th smallest key of the tree counting from zero. Like Rank, ranks
+// count expired and deleted keys; selecting one fails with a *errors.KeyError
+// wrapping errors.ErrKeyExpired or errors.ErrKeyNotFound, as Get does. A rank
+// outside [0, Len()) has no key to report, so that error wraps
+// errors.ErrKeyNotFound with the rank instead.
+func (bst *BST) Select(i int) (*kv.KVPair, error) {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+
+	rank := i
+	n := bst.root
+	for n != nil {
+		l := countOf(n.left)
+		if i < l {
+			n = n.left
+		} else if i > l {
+			i -= l + 1
+			n = n.right
+		} else {
+			return n.pair()
+		}
 	}
+	return nil, fmt.Errorf("%w: rank %d out of range [0, %d)", errors.ErrKeyNotFound, rank, countOf(bst.root))
+}
+
+// CountRange returns the number of keys k in the tree with start <= k < end.
+// Like Rank, it counts expired keys and deleted keys.
+func (bst *BST) CountRange(start, end []byte) int {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+
+	if bytes.Compare(start, end) >= 0 {
+		return 0
+	}
+	return bst.rank(end) - bst.rank(start)
+}
+
+// Floor returns a deep copy of the key/value pair with the greatest key that
+// is less than or equal to key. If that key is expired or deleted, Floor fails
+// like Get does for it.
+func (bst *BST) Floor(key []byte) (*kv.KVPair, error) {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
+
+	var floor *node
+	n := bst.root
+	for n != nil {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			floor = n
+			break
+		} else if cmp < 0 {
+			n = n.left
+		} else {
+			floor = n
+			n = n.right
+		}
+	}
+
+	if floor == nil {
+		return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: errors.ErrKeyNotFound}
+	}
+	return floor.pair()
+}
 
-	key := kv.HashKey(k)
-	nKey, _ := n.data.HashedKey()
+// Ceiling returns a deep copy of the key/value pair with the smallest key that
+// is greater than or equal to key. If that key is expired or deleted, Ceiling
+// fails like Get does for it.
+func (bst *BST) Ceiling(key []byte) (*kv.KVPair, error) {
+	bst.mu.RLock()
+	defer bst.mu.RUnlock()
 
-	if key == nKey {
-		pair, err := n.data.Clone()
-		if err != nil {
-			return nil, err
+	var ceiling *node
+	n := bst.root
+	for n != nil {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			ceiling = n
+			break
+		} else if cmp < 0 {
+			ceiling = n
+			n = n.left
+		} else {
+			n = n.right
 		}
-		return pair, nil
-	} else if key < nKey {
-		return getNode(n.left, k)
 	}
-	return getNode(n.right, k)
+
+	if ceiling == nil {
+		return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: errors.ErrKeyNotFound}
+	}
+	return ceiling.pair()
 }
 
-// inOrderTraversal traverses the tree in-order (left, n, right).
-func inOrderTraversal(n *node, r *[]*kv.KVPair) {
-	if n != nil {
-		inOrderTraversal(n.left, r)
-		if !n.data.IsExpired() {
-			kv, _ := n.data.Clone()
-			*r = append(*r, kv)
+// rank returns the number of keys in the tree that are smaller than key.
+func (bst *BST) rank(key []byte) int {
+	r := 0
+	n := bst.root
+	for n != nil {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			return r + countOf(n.left)
+		} else if cmp < 0 {
+			n = n.left
+		} else {
+			r += countOf(n.left) + 1
+			n = n.right
 		}
-		inOrderTraversal(n.right, r)
 	}
+	return r
 }
 
-// searchNode traverses the BST tree trying to find given k.
-func searchNode(n *node, k []byte) bool {
-	if n == nil {
-		return false
+// put links p into the tree. If its key is already in the tree, the current
+// pair is replaced by p. It must be called with the write lock held.
+func (bst *BST) put(p *kv.KVPair) {
+	key, _ := p.Key()
+
+	// Walk down to where the key belongs, remembering the path taken.
+	var path []*node
+	link := &bst.root
+	for n := *link; n != nil; n = *link {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			// ensure to update the current node if it already exists.
+			// The pair is swapped rather than modified, since iterators
+			// may still hold the current one.
+			bst.size += int64(p.Size() - n.data.Size())
+			n.data = p
+			return
+		}
+
+		path = append(path, n)
+		if cmp < 0 {
+			link = &n.left
+		} else {
+			link = &n.right
+		}
 	}
 
-	key := kv.HashKey(k)
-	nKey, _ := n.data.HashedKey()
+	*link = &node{key: key, data: p, height: 1, count: 1}
+	bst.len++
+	bst.size += int64(p.Size())
+	bst.rebalance(path)
+}
 
-	if key == nKey {
-		return true
-	} else if key < nKey {
-		return searchNode(n.left, k)
-	} else {
-		return searchNode(n.right, k)
-	}
-}
-
-func deleteNode(n *node, k []byte) *node {
-	// if n == nil {
-	// 	return nil
-	// }
-
-	// pkey := kv.hashkey(k)
-	// nkey := n.data.gethashedkey()
-
-	// if pkey < nkey {
-	// 	n.left = deletenode(n.left, k)
-	// } else if pkey > nkey {
-	// 	n.right = deletenode(n.right, k)
-	// } else {
-	// 	// node to be deleted is found
-	// 	if n.left == nil {
-	// 		return n.right
-	// 	} else if n.right == nil {
-	// 		return n.left
-	// 	}
-	// 	// node has two children
-	// 	minright := findmin(n.right)
-	// 	n.data = minright.data.copy()
-	// 	k, _ := minright.data.getkey()
-	// 	n.right = deletenode(n.right, k)
-	// }
-
-	// return n
+// find returns the node identified by key, or nil if there is none.
+func (bst *BST) find(key []byte) *node {
+	n := bst.root
+	for n != nil {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			return n
+		} else if cmp < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
 	return nil
 }
 
-func findMin(n *node) *node {
-	// current := n
-	// for current.left != nil {
-	// 	current = current.left
-	// }
-	// return current
+// walk traverses the tree in-order (left, n, right), calling fn for every
+// node whose key/value pair is neither expired nor deleted.
+func (bst *BST) walk(fn func(n *node)) {
+	var stack []*node
+	n := bst.root
+	for n != nil || len(stack) > 0 {
+		for n != nil {
+			stack = append(stack, n)
+			n = n.left
+		}
 
-	return nil
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !n.data.IsExpired() && !n.data.IsTombstone() {
+			fn(n)
+		}
+		n = n.right
+	}
+}
+
+// rebalance restores the AVL invariant bottom up along path, the nodes from
+// the root down to the parent of the node that was inserted or removed.
+func (bst *BST) rebalance(path []*node) {
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		b := balance(n)
+
+		if i == 0 {
+			bst.root = b
+		} else if parent := path[i-1]; parent.left == n {
+			parent.left = b
+		} else {
+			parent.right = b
+		}
+	}
+}
+
+// balance updates the height of n and rotates it if its subtrees differ in
+// height by more than one. It returns the new root of the subtree.
+func balance(n *node) *node {
+	n.update()
+
+	switch bf := n.balanceFactor(); {
+	case bf > 1:
+		if n.left.balanceFactor() < 0 {
+			n.left = rotateLeft(n.left)
+		}
+		return rotateRight(n)
+	case bf < -1:
+		if n.right.balanceFactor() > 0 {
+			n.right = rotateRight(n.right)
+		}
+		return rotateLeft(n)
+	}
+	return n
+}
+
+// rotateLeft rotates the subtree rooted at n to the left and returns its new root.
+func rotateLeft(n *node) *node {
+	r := n.right
+	n.right = r.left
+	r.left = n
+	n.update()
+	r.update()
+	return r
+}
+
+// rotateRight rotates the subtree rooted at n to the right and returns its new root.
+func rotateRight(n *node) *node {
+	l := n.left
+	n.left = l.right
+	l.right = n
+	n.update()
+	l.update()
+	return l
+}
+
+// pair returns a deep copy of the key/value pair of n. It fails with a
+// *errors.KeyError if the pair is expired or a tombstone.
+func (n *node) pair() (*kv.KVPair, error) {
+	if n.data.IsTombstone() {
+		return nil, &errors.KeyError{Key: append([]byte(nil), n.key...), Err: errors.ErrKeyNotFound}
+	}
+
+	pair, err := n.data.Clone()
+	if err != nil {
+		return nil, &errors.KeyError{Key: append([]byte(nil), n.key...), Err: err}
+	}
+	return pair, nil
+}
+
+// update recomputes the height and count of n from its children.
+func (n *node) update() {
+	n.height = 1 + max(heightOf(n.left), heightOf(n.right))
+	n.count = 1 + countOf(n.left) + countOf(n.right)
+}
+
+// balanceFactor returns the height of the left subtree of n minus the height
+// of its right subtree.
+func (n *node) balanceFactor() int {
+	return heightOf(n.left) - heightOf(n.right)
+}
+
+// countOf returns the number of nodes in the subtree rooted at n, zero if n
+// is nil.
+func countOf(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+// heightOf returns the height of the subtree rooted at n, zero if n is nil.
+func heightOf(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
 }
@@ -1,9 +1,16 @@
 package bst
 
 import (
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
+	"testing/quick"
 	"time"
 
+	errors "github.com/imariom/nexosdb/pkg/errors"
 	kv "github.com/imariom/nexosdb/pkg/kvpair"
 )
 
@@ -128,7 +135,7 @@ func TestBST_BatchInsertAndSearch(t *testing.T) {
 	// TODO: implement a batch insertion of KVPairs
 }
 
-func TestBST_ConcurrentInsertAndSearch() {
+func TestBST_ConcurrentInsertAndSearch(t *testing.T) {
 }
 
 func TestBST_UpdateAndSearch(t *testing.T) {
@@ -388,5 +395,330 @@ func TestBST_GetMethod(t *testing.T) {
 	}
 }
 
+func TestBST_InsertSizeLimits(t *testing.T) {
+	bst := &BST{MaxKeySize: 8, MaxValueSize: 16}
+
+	if err := bst.Insert(kv.NewKVPair([]byte("userID"), []byte("John Doe"), 0)); err != nil {
+		t.Fatalf("Expected insert within limits to succeed, got %v", err)
+	}
+
+	err := bst.Insert(kv.NewKVPair([]byte("userID123"), []byte("John Doe"), 0))
+	if !stderrors.Is(err, errors.ErrKeyTooLarge) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyTooLarge, err)
+	}
+
+	err = bst.Insert(kv.NewKVPair([]byte("email"), []byte("jane.doe@example.com"), 0))
+	if !stderrors.Is(err, errors.ErrValueTooLarge) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrValueTooLarge, err)
+	}
+
+	err = bst.Insert(kv.NewKVPair(nil, []byte("John Doe"), 0))
+	if !stderrors.Is(err, errors.ErrKeyRequired) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyRequired, err)
+	}
+
+	if bst.Search([]byte("userID123")) || bst.Search([]byte("email")) {
+		t.Errorf("Expected rejected pairs not to be in BST")
+	}
+}
+
 func TestBST_Delete(t *testing.T) {
+	bst := &BST{}
+	for i := 0; i < 10; i++ {
+		bst.Insert(kv.NewKVPair([]byte(fmt.Sprintf("key-%03d", i)), []byte("value"), 0))
+	}
+
+	// Deletes leave a tombstone behind, even for keys never inserted
+	for i := 0; i < 10; i += 2 {
+		if err := bst.Delete([]byte(fmt.Sprintf("key-%03d", i))); err != nil {
+			t.Fatalf("Expected delete to succeed, got %v", err)
+		}
+	}
+	if err := bst.Delete([]byte("missing")); err != nil {
+		t.Errorf("Expected delete of a missing key to succeed, got %v", err)
+	}
+	if err := bst.Delete(nil); !stderrors.Is(err, errors.ErrKeyRequired) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyRequired, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("key-%03d", i))
+		if found := bst.Search(key); found != (i%2 != 0) {
+			t.Errorf("Expected Search('%s') to be %v after delete", key, !found)
+		}
+		if _, err := bst.Get(key); (err == nil) != (i%2 != 0) {
+			t.Errorf("Expected Get('%s') to fail only for deleted keys, got %v", key, err)
+		}
+	}
+	if n := len(bst.InOrder()); n != 5 {
+		t.Errorf("Expected 5 live pairs after delete, got %d", n)
+	}
+	if bst.Len() != 11 {
+		t.Errorf("Expected tombstones to count towards Len, got %d", bst.Len())
+	}
+
+	// A deleted key can be inserted again
+	bst.Insert(kv.NewKVPair([]byte("key-000"), []byte("back"), 0))
+	if pair, err := bst.Get([]byte("key-000")); err != nil {
+		t.Errorf("Expected key to be readable after re-insert, got %v", err)
+	} else if value, _ := pair.Value(); string(value) != "back" {
+		t.Errorf("Expected value 'back', got '%s'", value)
+	}
+	checkBalanced(t, bst.root)
+}
+
+func TestBST_Remove(t *testing.T) {
+	bst := &BST{}
+	for i := 0; i < 100; i++ {
+		bst.Insert(kv.NewKVPair([]byte(fmt.Sprintf("key-%03d", i)), []byte("value"), 0))
+	}
+
+	// Remove every other key, covering leaves and nodes with two children
+	for i := 0; i < 100; i += 2 {
+		if err := bst.Remove([]byte(fmt.Sprintf("key-%03d", i))); err != nil {
+			t.Fatalf("Expected remove to succeed, got %v", err)
+		}
+	}
+	if err := bst.Remove([]byte("missing")); err != nil {
+		t.Errorf("Expected remove of a missing key to succeed, got %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%03d", i))
+		if found := bst.Search(key); found != (i%2 != 0) {
+			t.Errorf("Expected Search('%s') to be %v after remove", key, !found)
+		}
+	}
+	if bst.Len() != 50 {
+		t.Errorf("Expected 50 keys after remove, got %d", bst.Len())
+	}
+	if size := int64(50 * len("key-000value")); bst.ApproximateSize() != size {
+		t.Errorf("Expected size %d after remove, got %d", size, bst.ApproximateSize())
+	}
+	checkBalanced(t, bst.root)
+}
+
+func TestBST_SequentialInsertIsBalanced(t *testing.T) {
+	const n = 1 << 12
+
+	bst := &BST{}
+	for i := 0; i < n; i++ {
+		bst.Insert(kv.NewKVPair([]byte(fmt.Sprintf("key-%05d", i)), []byte("value"), 0))
+	}
+
+	// An AVL tree with n nodes is at most ~1.44*log2(n) high
+	if h := heightOf(bst.root); h > 18 {
+		t.Errorf("Expected height of at most 18 for %d sequential keys, got %d", n, h)
+	}
+	checkBalanced(t, bst.root)
+
+	pairs := bst.InOrder()
+	for i := 1; i < len(pairs); i++ {
+		prev, _ := pairs[i-1].Key()
+		cur, _ := pairs[i].Key()
+		if string(prev) >= string(cur) {
+			t.Fatalf("Expected '%s' to sort before '%s'", prev, cur)
+		}
+	}
+}
+
+// checkBalanced verifies the AVL invariant and the stored heights and counts
+// of the subtree rooted at n, returning its height.
+func checkBalanced(t *testing.T, n *node) int {
+	t.Helper()
+
+	if n == nil {
+		return 0
+	}
+	l, r := checkBalanced(t, n.left), checkBalanced(t, n.right)
+	if l-r > 1 || r-l > 1 {
+		t.Errorf("Node '%s' is unbalanced: left height %d, right height %d", n.key, l, r)
+	}
+	if h := 1 + max(l, r); n.height != h {
+		t.Errorf("Node '%s' has height %d, expected %d", n.key, n.height, h)
+	}
+	if c := 1 + countOf(n.left) + countOf(n.right); n.count != c {
+		t.Errorf("Node '%s' has count %d, expected %d", n.key, n.count, c)
+	}
+	return 1 + max(l, r)
+}
+
+// model is a sorted slice of keys used as the reference implementation in
+// the property tests below.
+type model []string
+
+// insert adds key to the model if it is not there yet.
+func (m *model) insert(key string) {
+	i := sort.SearchStrings(*m, key)
+	if i < len(*m) && (*m)[i] == key {
+		return
+	}
+	*m = slices.Insert(*m, i, key)
+}
+
+// delete removes key from the model if it is there.
+func (m *model) delete(key string) {
+	i := sort.SearchStrings(*m, key)
+	if i < len(*m) && (*m)[i] == key {
+		*m = slices.Delete(*m, i, i+1)
+	}
+}
+
+// propKey maps a random number to one of a small set of keys, so that
+// operations often hit keys that already exist.
+func propKey(r uint16) string {
+	return fmt.Sprintf("key-%03d", r%64)
+}
+
+// pairKey returns the key of pair, or an empty string on error.
+func pairKey(pair *kv.KVPair, err error) string {
+	if err != nil {
+		return ""
+	}
+	key, _ := pair.Key()
+	return string(key)
+}
+
+func TestBST_PropertyModel(t *testing.T) {
+	// Every op inserts when its top bit is clear and deletes otherwise
+	property := func(ops []uint16, probes []uint16) bool {
+		bst := &BST{}
+		var m model
+		for _, op := range ops {
+			key := propKey(op)
+			if op&0x8000 == 0 {
+				bst.Insert(kv.NewKVPair([]byte(key), []byte("value"), 0))
+				m.insert(key)
+			} else {
+				bst.Remove([]byte(key))
+				m.delete(key)
+			}
+		}
+
+		checkBalanced(t, bst.root)
+		if bst.Len() != len(m) {
+			t.Logf("Len: expected %d, got %d", len(m), bst.Len())
+			return false
+		}
+
+		var keys []string
+		for _, pair := range bst.InOrder() {
+			keys = append(keys, pairKey(pair, nil))
+		}
+		if !slices.Equal(keys, m) {
+			t.Logf("InOrder: expected %v, got %v", m, keys)
+			return false
+		}
+
+		for i, key := range m {
+			if got := pairKey(bst.Select(i)); got != key {
+				t.Logf("Select(%d): expected '%s', got '%s'", i, key, got)
+				return false
+			}
+		}
+		if _, err := bst.Select(len(m)); err == nil {
+			t.Logf("Select(%d): expected an error", len(m))
+			return false
+		}
+
+		for j, p := range probes {
+			key := propKey(p)
+			rank := sort.SearchStrings(m, key)
+			if got := bst.Rank([]byte(key)); got != rank {
+				t.Logf("Rank('%s'): expected %d, got %d", key, rank, got)
+				return false
+			}
+
+			floor, ceiling := "", ""
+			if rank < len(m) {
+				ceiling = m[rank]
+			}
+			if rank < len(m) && m[rank] == key {
+				floor = key
+			} else if rank > 0 {
+				floor = m[rank-1]
+			}
+			if got := pairKey(bst.Floor([]byte(key))); got != floor {
+				t.Logf("Floor('%s'): expected '%s', got '%s'", key, floor, got)
+				return false
+			}
+			if got := pairKey(bst.Ceiling([]byte(key))); got != ceiling {
+				t.Logf("Ceiling('%s'): expected '%s', got '%s'", key, ceiling, got)
+				return false
+			}
+
+			end := propKey(probes[(j+1)%len(probes)])
+			count := 0
+			for _, k := range m {
+				if k >= key && k < end {
+					count++
+				}
+			}
+			if got := bst.CountRange([]byte(key), []byte(end)); got != count {
+				t.Logf("CountRange('%s', '%s'): expected %d, got %d", key, end, count, got)
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBST_IteratorSnapshot(t *testing.T) {
+	bst := &BST{}
+	bst.Insert(kv.NewKVPair([]byte("userID123"), []byte("v1"), 0))
+
+	it := bst.NewIterator()
+	defer it.Close()
+
+	// Update the key while the iterator is read concurrently
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bst.Insert(kv.NewKVPair([]byte("userID123"), []byte("v2"), 0))
+	}()
+
+	it.SeekToFirst()
+	pair, err := it.Pair()
+	<-done
+	if err != nil {
+		t.Fatalf("Expected pair, got %v", err)
+	}
+	if value, _ := pair.Value(); string(value) != "v1" {
+		t.Errorf("Expected snapshot value 'v1', got '%s'", value)
+	}
+
+	if pair, _ := bst.Get([]byte("userID123")); pair == nil {
+		t.Fatalf("Expected updated pair in the tree")
+	} else if value, _ := pair.Value(); string(value) != "v2" {
+		t.Errorf("Expected updated value 'v2', got '%s'", value)
+	}
+}
+
+func TestBST_SelectErrors(t *testing.T) {
+	bst := &BST{}
+	bst.Insert(kv.NewKVPair([]byte("a"), []byte("value"), 0))
+	bst.Insert(kv.NewKVPair([]byte("b"), []byte("value"), time.Millisecond))
+	bst.Insert(kv.NewKVPair([]byte("c"), []byte("value"), 0))
+	bst.Delete([]byte("c"))
+	time.Sleep(time.Millisecond * 5)
+
+	// Expired and deleted keys keep their rank
+	if got := bst.CountRange([]byte("a"), []byte("d")); got != 3 {
+		t.Errorf("Expected CountRange to count 3 keys, got %d", got)
+	}
+
+	var ke *errors.KeyError
+	if _, err := bst.Select(1); !stderrors.As(err, &ke) || string(ke.Key) != "b" || !stderrors.Is(err, errors.ErrKeyExpired) {
+		t.Errorf("Expected KeyError for expired 'b', got %v", err)
+	}
+	if _, err := bst.Select(2); !stderrors.As(err, &ke) || string(ke.Key) != "c" || !stderrors.Is(err, errors.ErrKeyNotFound) {
+		t.Errorf("Expected KeyError for deleted 'c', got %v", err)
+	}
+	if _, err := bst.Select(3); !stderrors.Is(err, errors.ErrKeyNotFound) || !strings.Contains(err.Error(), "rank 3") {
+		t.Errorf("Expected out of range error reporting the rank, got %v", err)
+	}
 }
@@ -2,7 +2,10 @@
 // during nexus operations.
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrKeyNotValid is returned when trying to access/operate on a key/value pair
@@ -34,13 +37,196 @@ var (
 	// ErrKeyRequired is returned when inserting a zero-length key.
 	ErrKeyRequired = errors.New("key required")
 
-	// ErrKeyTooLarge is returned when inserting a key that is larger than MaxKeySize.
+	// ErrKeyTooLarge is returned when inserting a key that is larger than kvpair.MaxKeySize
+	// or the limit configured for the write.
 	ErrKeyTooLarge = errors.New("key too large")
 
-	// ErrValueTooLarge is returned when inserting a value that is larger than MaxValueSize.
+	// ErrValueTooLarge is returned when inserting a value that is larger than kvpair.MaxValueSize
+	// or the limit configured for the write.
 	ErrValueTooLarge = errors.New("value too large")
 
+	// ErrMemtableFull is returned when a memtable has no room left for a
+	// write and must be flushed before accepting more.
+	ErrMemtableFull = errors.New("memtable full")
+
 	// ErrNodeIsNil is returned when trying to access/operate on a node
 	// tha is nil.
 	ErrNodeIsNil = errors.New("tree node is nil")
 )
+
+// These errors are wrapped by the structured error types below and can be
+// matched with errors.Is.
+var (
+	// ErrCorruption is returned when data read from disk does not match
+	// what was written, e.g. a bad checksum or a truncated record.
+	ErrCorruption = errors.New("data corruption")
+
+	// ErrIO is returned when an underlying file system operation fails.
+	ErrIO = errors.New("i/o error")
+)
+
+// CorruptionError reports corrupted data found at Offset within File.
+// It matches ErrCorruption with errors.Is.
+type CorruptionError struct {
+	// File is the path of the file holding the corrupted data, empty when
+	// the data did not come from a file.
+	File string
+
+	// Offset is the byte offset within File at which corruption was detected.
+	Offset int64
+
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+func (e *CorruptionError) Error() string {
+	msg := fmt.Sprintf("%v at offset %d", ErrCorruption, e.Offset)
+	if e.File != "" {
+		msg = fmt.Sprintf("%v in %s at offset %d", ErrCorruption, e.File, e.Offset)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is ErrCorruption.
+func (e *CorruptionError) Is(target error) bool { return target == ErrCorruption }
+
+// Unwrap returns the underlying cause.
+func (e *CorruptionError) Unwrap() error { return e.Err }
+
+// IOError reports a failed file system operation Op on Path.
+// It matches ErrIO with errors.Is.
+type IOError struct {
+	// Op is the operation that failed, e.g. "open" or "sync".
+	Op string
+
+	// Path is the file or directory the operation was applied to.
+	Path string
+
+	// Err is the underlying cause, usually an *os.PathError.
+	Err error
+}
+
+func (e *IOError) Error() string {
+	return fmt.Sprintf("%v: %s %s: %v", ErrIO, e.Op, e.Path, e.Err)
+}
+
+// Is reports whether target is ErrIO.
+func (e *IOError) Is(target error) bool { return target == ErrIO }
+
+// Unwrap returns the underlying cause.
+func (e *IOError) Unwrap() error { return e.Err }
+
+// KeyError reports an error that happened while operating on Key.
+// It wraps one of the key sentinels, e.g. ErrKeyNotFound.
+type KeyError struct {
+	// Key is the key the operation failed on.
+	Key []byte
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key %q: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error { return e.Err }
+
+// Code is a stable numeric identifier of an error, meant to be carried over
+// network protocols. Values are never reused or renumbered.
+type Code uint16
+
+const (
+	// CodeOK means no error.
+	CodeOK Code = 0
+
+	// CodeUnknown stands for an error that matches no sentinel.
+	CodeUnknown Code = 1
+
+	// CodeKeyNotValid stands for ErrKeyNotValid.
+	CodeKeyNotValid Code = 2
+
+	// CodeDatabaseNotOpen stands for ErrDatabaseNotOpen.
+	CodeDatabaseNotOpen Code = 3
+
+	// CodeTimeout stands for ErrTimeout.
+	CodeTimeout Code = 4
+
+	// CodeKeyNotFound stands for ErrKeyNotFound.
+	CodeKeyNotFound Code = 5
+
+	// CodeKeyExpired stands for ErrKeyExpired.
+	CodeKeyExpired Code = 6
+
+	// CodeKeyRequired stands for ErrKeyRequired.
+	CodeKeyRequired Code = 7
+
+	// CodeKeyTooLarge stands for ErrKeyTooLarge.
+	CodeKeyTooLarge Code = 8
+
+	// CodeValueTooLarge stands for ErrValueTooLarge.
+	CodeValueTooLarge Code = 9
+
+	// CodeNodeIsNil stands for ErrNodeIsNil.
+	CodeNodeIsNil Code = 10
+
+	// CodeCorruption stands for ErrCorruption and *CorruptionError.
+	CodeCorruption Code = 11
+
+	// CodeIO stands for ErrIO and *IOError.
+	CodeIO Code = 12
+
+	// CodeMemtableFull stands for ErrMemtableFull.
+	CodeMemtableFull Code = 13
+)
+
+// codes maps each sentinel error to its Code.
+var codes = []struct {
+	code Code
+	err  error
+}{
+	{CodeKeyNotValid, ErrKeyNotValid},
+	{CodeDatabaseNotOpen, ErrDatabaseNotOpen},
+	{CodeTimeout, ErrTimeout},
+	{CodeKeyNotFound, ErrKeyNotFound},
+	{CodeKeyExpired, ErrKeyExpired},
+	{CodeKeyRequired, ErrKeyRequired},
+	{CodeKeyTooLarge, ErrKeyTooLarge},
+	{CodeValueTooLarge, ErrValueTooLarge},
+	{CodeNodeIsNil, ErrNodeIsNil},
+	{CodeCorruption, ErrCorruption},
+	{CodeIO, ErrIO},
+	{CodeMemtableFull, ErrMemtableFull},
+}
+
+// CodeOf returns the Code of the first sentinel error found in err's chain.
+// It returns CodeOK for a nil error and CodeUnknown if no sentinel matches.
+func CodeOf(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return CodeUnknown
+}
+
+// FromCode returns the sentinel error identified by code.
+// It returns nil for CodeOK and an unknown error for unrecognized codes.
+func FromCode(code Code) error {
+	if code == CodeOK {
+		return nil
+	}
+	for _, c := range codes {
+		if c.code == code {
+			return c.err
+		}
+	}
+	return fmt.Errorf("unknown error code %d", code)
+}
@@ -0,0 +1,81 @@
+package errors
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestCorruptionError(t *testing.T) {
+	cause := errors.New("checksum mismatch")
+	err := error(&CorruptionError{File: "000001.sst", Offset: 4096, Err: cause})
+
+	if !errors.Is(err, ErrCorruption) {
+		t.Errorf("Expected error to match ErrCorruption")
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("Expected error to wrap its cause")
+	}
+
+	var ce *CorruptionError
+	if !errors.As(err, &ce) || ce.File != "000001.sst" || ce.Offset != 4096 {
+		t.Errorf("Expected to recover file and offset, got %+v", ce)
+	}
+
+	want := "data corruption in 000001.sst at offset 4096: checksum mismatch"
+	if err.Error() != want {
+		t.Errorf("Expected message '%s', got '%s'", want, err.Error())
+	}
+}
+
+func TestIOError(t *testing.T) {
+	err := error(&IOError{Op: "open", Path: "/tmp/nexos/LOCK", Err: fs.ErrPermission})
+
+	if !errors.Is(err, ErrIO) || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected error to match ErrIO and fs.ErrPermission, got %v", err)
+	}
+	if CodeOf(err) != CodeIO {
+		t.Errorf("Expected code %d, got %d", CodeIO, CodeOf(err))
+	}
+}
+
+func TestKeyError(t *testing.T) {
+	err := error(&KeyError{Key: []byte("userID123"), Err: ErrKeyNotFound})
+
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected error to match ErrKeyNotFound")
+	}
+
+	var ke *KeyError
+	if !errors.As(err, &ke) || string(ke.Key) != "userID123" {
+		t.Errorf("Expected to recover key, got %+v", ke)
+	}
+}
+
+func TestCodes(t *testing.T) {
+	if CodeOf(nil) != CodeOK || FromCode(CodeOK) != nil {
+		t.Errorf("Expected nil error to map to CodeOK")
+	}
+	if CodeOf(errors.New("boom")) != CodeUnknown {
+		t.Errorf("Expected unrecognized error to map to CodeUnknown")
+	}
+
+	seen := make(map[Code]bool)
+	for _, c := range codes {
+		if seen[c.code] {
+			t.Errorf("Duplicate code %d", c.code)
+		}
+		seen[c.code] = true
+
+		if CodeOf(c.err) != c.code {
+			t.Errorf("Expected '%v' to map to %d, got %d", c.err, c.code, CodeOf(c.err))
+		}
+		if FromCode(c.code) != c.err {
+			t.Errorf("Expected code %d to map to '%v', got '%v'", c.code, c.err, FromCode(c.code))
+		}
+	}
+
+	if FromCode(Code(65535)) == nil {
+		t.Errorf("Expected an error for an unknown code")
+	}
+}
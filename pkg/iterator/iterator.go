@@ -0,0 +1,94 @@
+// Package iterator defines the Iterator interface used to walk ordered
+// key/value pairs, and a slice backed implementation of it.
+package iterator
+
+import (
+	"bytes"
+	"sort"
+
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+// Iterator iterates over key/value pairs in ascending key order.
+// A new iterator is not positioned; call SeekToFirst or Seek first.
+type Iterator interface {
+	// SeekToFirst positions the iterator at the first pair.
+	SeekToFirst()
+
+	// Seek positions the iterator at the first pair whose key is greater
+	// than or equal to key.
+	Seek(key []byte)
+
+	// Valid reports whether the iterator is positioned at a pair.
+	Valid() bool
+
+	// Next moves the iterator to the next pair. Valid must be true.
+	Next()
+
+	// Key returns the key of the current pair. The caller must not modify it.
+	// Valid must be true.
+	Key() []byte
+
+	// Pair returns a deep copy of the current pair. Valid must be true.
+	Pair() (*kv.KVPair, error)
+
+	// Close releases the resources held by the iterator.
+	Close() error
+}
+
+// SliceIterator is an Iterator over a fixed, sorted set of pairs.
+type SliceIterator struct {
+	// keys holds the key of each pair, in ascending order.
+	keys [][]byte
+
+	// pairs holds the pairs, in the same order as keys.
+	pairs []*kv.KVPair
+
+	// pos is the current position, len(keys) when not positioned.
+	pos int
+}
+
+// NewSliceIterator returns an iterator over pairs. keys[i] must be the key of
+// pairs[i] and keys must be sorted in ascending order without duplicates.
+// Neither slice is copied.
+func NewSliceIterator(keys [][]byte, pairs []*kv.KVPair) *SliceIterator {
+	return &SliceIterator{keys: keys, pairs: pairs, pos: len(keys)}
+}
+
+// SeekToFirst positions the iterator at the first pair.
+func (it *SliceIterator) SeekToFirst() {
+	it.pos = 0
+}
+
+// Seek positions the iterator at the first pair whose key is >= key.
+func (it *SliceIterator) Seek(key []byte) {
+	it.pos = sort.Search(len(it.keys), func(i int) bool {
+		return bytes.Compare(it.keys[i], key) >= 0
+	})
+}
+
+// Valid reports whether the iterator is positioned at a pair.
+func (it *SliceIterator) Valid() bool {
+	return it.pos < len(it.keys)
+}
+
+// Next moves the iterator to the next pair.
+func (it *SliceIterator) Next() {
+	it.pos++
+}
+
+// Key returns the key of the current pair.
+func (it *SliceIterator) Key() []byte {
+	return it.keys[it.pos]
+}
+
+// Pair returns a deep copy of the current pair.
+func (it *SliceIterator) Pair() (*kv.KVPair, error) {
+	return it.pairs[it.pos].Clone()
+}
+
+// Close releases the pairs held by the iterator.
+func (it *SliceIterator) Close() error {
+	it.keys, it.pairs, it.pos = nil, nil, 0
+	return nil
+}
@@ -0,0 +1,247 @@
+package kvpair
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/imariom/nexosdb/pkg/errors"
+)
+
+// The binary encoding of a KVPair is, in order:
+//
+//	flags      1 byte, the Kind in the low bits and flagExpiration
+//	seqNum     uvarint
+//	updatedAt  varint, unix nanoseconds, 0 for the zero time
+//	expiration varint, unix nanoseconds, only present with flagExpiration
+//	key        uvarint length followed by the key bytes
+//	value      uvarint length followed by the value bytes
+//
+// Timestamps outside the years 1678 to 2262 are not representable and are
+// clamped to that range.
+const (
+	// kindMask selects the Kind bits of the flags byte.
+	kindMask = 0x0f
+
+	// flagExpiration is set when the pair has an expiration.
+	flagExpiration = 0x10
+
+	// maxKind is the largest Kind known to the decoder.
+	maxKind = KindDelete
+)
+
+// EncodedSize returns the number of bytes of the binary encoding of the KVPair.
+func (kv *KVPair) EncodedSize() int {
+	n := 1 + uvarintLen(kv.seqNum) + varintLen(UnixNano(kv.updatedAt))
+	if !kv.expiration.IsZero() {
+		n += varintLen(UnixNano(kv.expiration))
+	}
+	n += uvarintLen(uint64(len(kv.key))) + len(kv.key)
+	n += uvarintLen(uint64(len(kv.value))) + len(kv.value)
+	return n
+}
+
+// AppendEncode appends the binary encoding of the KVPair to dst and returns
+// the extended slice. It does not allocate when dst has EncodedSize bytes of
+// spare capacity.
+func (kv *KVPair) AppendEncode(dst []byte) []byte {
+	flags := byte(kv.kind) & kindMask
+	if !kv.expiration.IsZero() {
+		flags |= flagExpiration
+	}
+
+	dst = append(dst, flags)
+	dst = binary.AppendUvarint(dst, kv.seqNum)
+	dst = binary.AppendVarint(dst, UnixNano(kv.updatedAt))
+	if !kv.expiration.IsZero() {
+		dst = binary.AppendVarint(dst, UnixNano(kv.expiration))
+	}
+	dst = binary.AppendUvarint(dst, uint64(len(kv.key)))
+	dst = append(dst, kv.key...)
+	dst = binary.AppendUvarint(dst, uint64(len(kv.value)))
+	dst = append(dst, kv.value...)
+	return dst
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Expired pairs can be
+// encoded, invalid ones cannot.
+func (kv *KVPair) MarshalBinary() ([]byte, error) {
+	if !kv.IsValid() {
+		return nil, errors.ErrKeyNotValid
+	}
+	return kv.AppendEncode(make([]byte, 0, kv.EncodedSize())), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. data must hold
+// exactly one encoded KVPair. The key and value are copied out of data.
+func (kv *KVPair) UnmarshalBinary(data []byte) error {
+	n, err := kv.Decode(data)
+	if err != nil {
+		return err
+	} else if n != len(data) {
+		return &errors.CorruptionError{Offset: int64(n), Err: fmt.Errorf("%d trailing bytes", len(data)-n)}
+	}
+
+	kv.key = append([]byte(nil), kv.key...)
+	kv.value = append([]byte(nil), kv.value...)
+	return nil
+}
+
+// Decode decodes a KVPair from the start of data and returns the number of
+// bytes consumed, so that consecutive pairs can be read from a buffer without
+// allocating. The key and value alias data, which must not be modified while
+// the KVPair is in use. Malformed input fails with an *errors.CorruptionError.
+func (kv *KVPair) Decode(data []byte) (int, error) {
+	d := decoder{data: data}
+
+	flags := d.byte()
+	if flags&^(kindMask|flagExpiration) != 0 || Kind(flags&kindMask) > maxKind {
+		d.fail(fmt.Errorf("invalid flags %#x", flags))
+	}
+	seqNum := d.uvarint()
+	updatedAt := FromUnixNano(d.varint())
+	var expiration time.Time
+	if flags&flagExpiration != 0 {
+		expiration = time.Unix(0, d.varint())
+	}
+	key := d.bytes()
+	value := d.bytes()
+
+	if d.err == nil && (len(key) == 0 || (len(value) == 0) != (Kind(flags&kindMask) == KindDelete)) {
+		d.fail(errors.ErrKeyNotValid)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	*kv = KVPair{
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		updatedAt:  updatedAt,
+		kind:       Kind(flags & kindMask),
+		seqNum:     seqNum,
+	}
+	return d.off, nil
+}
+
+// decoder reads the fields of an encoded KVPair, recording the first error.
+type decoder struct {
+	// data is the buffer being decoded.
+	data []byte
+
+	// off is the offset of the next field in data.
+	off int
+
+	// err is the first error found, after which reads return zero values.
+	err error
+}
+
+// fail records err at the current offset if no error was recorded yet.
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = &errors.CorruptionError{Offset: int64(d.off), Err: err}
+	}
+}
+
+// byte reads a single byte.
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	} else if d.off >= len(d.data) {
+		d.fail(fmt.Errorf("unexpected end of data"))
+		return 0
+	}
+	d.off++
+	return d.data[d.off-1]
+}
+
+// uvarint reads an unsigned varint.
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.off:])
+	if n <= 0 {
+		d.fail(fmt.Errorf("invalid uvarint"))
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+// varint reads a signed varint.
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.off:])
+	if n <= 0 {
+		d.fail(fmt.Errorf("invalid varint"))
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+// bytes reads a length prefixed byte slice, aliasing data.
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	} else if n > uint64(len(d.data)-d.off) {
+		d.fail(fmt.Errorf("length %d exceeds remaining %d bytes", n, len(d.data)-d.off))
+		return nil
+	}
+	b := d.data[d.off : d.off+int(n) : d.off+int(n)]
+	d.off += int(n)
+	return b
+}
+
+// minTime and maxTime are the earliest and latest times representable as
+// unix nanoseconds.
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+// UnixNano returns t as unix nanoseconds, zero for the zero time, as stored
+// by the binary encoding. Times outside the range of unix nanoseconds,
+// roughly the years 1678 to 2262, are clamped to it rather than wrapping
+// around.
+func UnixNano(t time.Time) int64 {
+	switch {
+	case t.IsZero():
+		return 0
+	case t.Before(minTime):
+		return math.MinInt64
+	case t.After(maxTime):
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
+
+// FromUnixNano is the inverse of UnixNano, zero is the zero time.
+func FromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// uvarintLen returns the length of the uvarint encoding of v.
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// varintLen returns the length of the varint encoding of v.
+func varintLen(v int64) int {
+	// Same zig-zag mapping as binary.PutVarint.
+	return uvarintLen(uint64(v<<1) ^ uint64(v>>63))
+}
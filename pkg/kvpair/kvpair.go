@@ -5,12 +5,22 @@ package kvpair
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/imariom/nexosdb/pkg/errors"
 )
 
+// Default size limits applied to key/value pairs on write.
+const (
+	// MaxKeySize is the maximum length of a key, in bytes.
+	MaxKeySize = 32768
+
+	// MaxValueSize is the maximum length of a value, in bytes.
+	MaxValueSize = (1 << 31) - 2
+)
+
 // KVPair represents a key-value pair with metadata, including expiration and update timestamps.
 type KVPair struct {
 	// key is the unique identifier for this key-value pair.
@@ -28,8 +38,26 @@ type KVPair struct {
 	// updatedAt records the most recent time at which the key-value pair was modified.
 	// This timestamp is useful for tracking changes and implementing caching or consistency mechanisms.
 	updatedAt time.Time
+
+	// kind tells whether the pair holds a value or marks the key as deleted.
+	kind Kind
+
+	// seqNum is the sequence number of the write that produced the pair,
+	// zero until one is assigned.
+	seqNum uint64
 }
 
+// Kind is the type of a KVPair.
+type Kind uint8
+
+const (
+	// KindValue is a regular key/value pair.
+	KindValue Kind = iota
+
+	// KindDelete is a tombstone marking its key as deleted. It has no value.
+	KindDelete
+)
+
 // NewKVPair creates and returns a new KVPair with the provided key and value.
 // If a ttl (Time-To-Live) duration is specified and greater than zero, expiration is set accordingly.
 // Otherwise, expiration is set to a zero value, indicating no expiration.
@@ -47,6 +75,27 @@ func NewKVPair(key, value []byte, ttl time.Duration) *KVPair {
 	}
 }
 
+// NewTombstone creates and returns a KVPair of KindDelete marking key as deleted.
+func NewTombstone(key []byte) *KVPair {
+	return &KVPair{
+		key:       key,
+		updatedAt: time.Now(),
+		kind:      KindDelete,
+	}
+}
+
+// Restore creates a KVPair from previously stored fields, keeping the given
+// expiration and updatedAt timestamps instead of deriving them from a TTL and
+// the current time. The key and value slices are not copied.
+func Restore(key, value []byte, expiration, updatedAt time.Time) *KVPair {
+	return &KVPair{
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		updatedAt:  updatedAt,
+	}
+}
+
 // UpdateValue updates the value of the KVPair and refreshes the updateAt timestamp.
 func (kv *KVPair) UpdateValue(newValue []byte) error {
 	if err := kv.Validate(); err != nil {
@@ -82,8 +131,10 @@ func (kv *KVPair) UpdateWith(other *KVPair) error {
 		return err
 	}
 
-	kv.value = append(kv.value, other.value...)
+	kv.value = other.value
 	kv.expiration = other.expiration
+	kv.kind = other.kind
+	kv.seqNum = other.seqNum
 	kv.updatedAt = time.Now()
 	return nil
 }
@@ -148,6 +199,8 @@ func (kv *KVPair) Clone() (*KVPair, error) {
 		value:      nv,
 		expiration: kv.expiration,
 		updatedAt:  kv.updatedAt,
+		kind:       kv.kind,
+		seqNum:     kv.seqNum,
 	}, nil
 }
 
@@ -164,17 +217,46 @@ func (kv *KVPair) Move() (*KVPair, error) {
 		value:      kv.value,
 		expiration: kv.expiration,
 		updatedAt:  kv.updatedAt,
+		kind:       kv.kind,
+		seqNum:     kv.seqNum,
 	}
 
 	// Invalidate the current KVPair by setting fields to zero values
 	kv.key = nil
 	kv.value = nil
 	kv.expiration = time.Time{}
-	kv.expiration = time.Time{}
+	kv.updatedAt = time.Time{}
+	kv.kind = KindValue
+	kv.seqNum = 0
 
 	return tmp, nil
 }
 
+// Kind returns the kind of the KVPair.
+func (kv *KVPair) Kind() Kind {
+	return kv.kind
+}
+
+// IsTombstone reports whether the KVPair marks its key as deleted.
+func (kv *KVPair) IsTombstone() bool {
+	return kv.kind == KindDelete
+}
+
+// SeqNum returns the sequence number of the KVPair.
+func (kv *KVPair) SeqNum() uint64 {
+	return kv.seqNum
+}
+
+// SetSeqNum assigns the sequence number of the write that produced the KVPair.
+func (kv *KVPair) SetSeqNum(seqNum uint64) {
+	kv.seqNum = seqNum
+}
+
+// Size returns the number of bytes taken by the key and the value of the KVPair.
+func (kv *KVPair) Size() int {
+	return len(kv.key) + len(kv.value)
+}
+
 // IsExpired checks if the KVPair has expired. If the expiration time is zero, it is considered non-expiring.
 func (kv *KVPair) IsExpired() bool {
 	if kv.expiration.IsZero() {
@@ -185,10 +267,10 @@ func (kv *KVPair) IsExpired() bool {
 
 // IsValid checks if the current KVPair is valid.
 // A KVPair is considered valid if:
-// - The key and value are non-nil and non-empty.
+// - The key and value are non-nil and non-empty. Tombstones must have no value.
 // - The expiration is either unset or set to a future time.
 func (kv *KVPair) IsValid() bool {
-	if len(kv.key) == 0 || len(kv.value) == 0 {
+	if len(kv.key) == 0 || (len(kv.value) == 0) != (kv.kind == KindDelete) {
 		return false
 	}
 	// if !kv.expiration.IsZero() && kv.expiration.Before(time.Now()) {
@@ -221,12 +303,43 @@ func (kv *KVPair) Validate() error {
 	return nil
 }
 
-// Equal checks if two KVPairs have the same key, value, expiration, and update times.
+// CheckSize verifies that the key and value of the KVPair are within the given
+// size limits. See the package level CheckSize for details.
+func (kv *KVPair) CheckSize(maxKeySize, maxValueSize int) error {
+	return CheckSize(kv.key, kv.value, maxKeySize, maxValueSize)
+}
+
+// Equal checks if two KVPairs have the same key, value, expiration, update times,
+// kind and sequence number.
 func (kv *KVPair) Equal(other *KVPair) bool {
 	return bytes.Equal(kv.key, other.key) &&
 		bytes.Equal(kv.value, other.value) &&
 		kv.expiration.Equal(other.expiration) &&
-		kv.updatedAt.Equal(other.updatedAt)
+		kv.updatedAt.Equal(other.updatedAt) &&
+		kv.kind == other.kind &&
+		kv.seqNum == other.seqNum
+}
+
+// CheckSize verifies that key and value are within the given size limits.
+// A limit of zero or less falls back to MaxKeySize or MaxValueSize.
+// The returned error wraps errors.ErrKeyRequired, errors.ErrKeyTooLarge or
+// errors.ErrValueTooLarge and reports the offending size.
+func CheckSize(key, value []byte, maxKeySize, maxValueSize int) error {
+	if maxKeySize <= 0 {
+		maxKeySize = MaxKeySize
+	}
+	if maxValueSize <= 0 {
+		maxValueSize = MaxValueSize
+	}
+
+	if len(key) == 0 {
+		return errors.ErrKeyRequired
+	} else if len(key) > maxKeySize {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", errors.ErrKeyTooLarge, len(key), maxKeySize)
+	} else if len(value) > maxValueSize {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", errors.ErrValueTooLarge, len(value), maxValueSize)
+	}
+	return nil
 }
 
 // HashedKey transform keys into a fixed-length SHA-256 hash.
@@ -237,6 +350,5 @@ func HashKey(key []byte) string {
 // getHashedKey generates a SHA-256 hash of a given key and returns it as a hexadecimal string.
 func getHashedKey(key []byte) string {
 	hash := sha256.Sum256(key)
-	hashstr := fmt.Sprintf("%x", hash)
-	return hashstr
+	return hex.EncodeToString(hash[:])
 }
@@ -1,6 +1,10 @@
 package kvpair
 
 import (
+	"bytes"
+	stderrors "errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,6 +55,13 @@ func TestValidate(t *testing.T) {
 		t.Errorf("Expected 'ErrKeyNotValid' error, got: %v", err)
 	}
 
+	// Invalid tombstone (non-empty value)
+	kv = NewTombstone(key)
+	kv.value = value
+	if err := kv.Validate(); err != errors.ErrKeyNotValid {
+		t.Errorf("Expected 'ErrKeyNotValid' error, got: %v", err)
+	}
+
 	// Expired KVPair
 	kv = NewKVPair(key, value, ttl)
 	kv.expiration = time.Now().Add(-time.Minute * 10) // Set expiration in the past
@@ -207,3 +218,245 @@ func TestHashedKey(t *testing.T) {
 		t.Errorf("Expected SHA-256 hash to be 64 characters long, got: %s", hashedKey)
 	}
 }
+
+// Test case for the CheckSize function
+func TestCheckSize(t *testing.T) {
+	tests := []struct {
+		key      []byte
+		value    []byte
+		maxKey   int
+		maxValue int
+		err      error
+	}{
+		{[]byte("userID123"), []byte("John Doe"), 0, 0, nil},
+		{nil, []byte("John Doe"), 0, 0, errors.ErrKeyRequired},
+		{[]byte("userID123"), []byte("John Doe"), 4, 0, errors.ErrKeyTooLarge},
+		{[]byte("userID123"), []byte("John Doe"), 0, 4, errors.ErrValueTooLarge},
+		{make([]byte, MaxKeySize+1), []byte("John Doe"), 0, 0, errors.ErrKeyTooLarge},
+		{[]byte("userID123"), []byte("John Doe"), 9, 8, nil},
+	}
+
+	for _, test := range tests {
+		err := CheckSize(test.key, test.value, test.maxKey, test.maxValue)
+		if !stderrors.Is(err, test.err) {
+			t.Errorf("Expected '%v' error for key of %d bytes, got: %v", test.err, len(test.key), err)
+		}
+	}
+
+	// The offending size should be reported in the error
+	err := NewKVPair([]byte("userID123"), []byte("John Doe"), 0).CheckSize(4, 0)
+	if err == nil || !strings.Contains(err.Error(), "9 bytes") {
+		t.Errorf("Expected error to report key size, got: %v", err)
+	}
+}
+
+// Test case for the MarshalBinary and UnmarshalBinary methods
+func TestMarshalBinary(t *testing.T) {
+	withSeq := NewKVPair([]byte("sessionToken"), []byte("abc123xyz"), 0)
+	withSeq.SetSeqNum(1 << 40)
+
+	tests := []*KVPair{
+		NewKVPair([]byte("userID123"), []byte("John Doe"), time.Minute*5),
+		NewKVPair([]byte("permanentUserID"), []byte("user123456"), 0),
+		NewKVPair([]byte("binaryData"), []byte{0x00, 0xFF, 0x0A}, time.Hour),
+		NewKVPair([]byte("largeValue"), bytes.Repeat([]byte("x"), 1<<16), 0),
+		NewTombstone([]byte("deletedKey")),
+		withSeq,
+	}
+
+	for _, kv := range tests {
+		data, err := kv.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Expected successful marshal, got error: %v", err)
+		}
+		if len(data) != kv.EncodedSize() {
+			t.Errorf("Expected %d encoded bytes, got %d", kv.EncodedSize(), len(data))
+		}
+
+		var decoded KVPair
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Expected successful unmarshal, got error: %v", err)
+		}
+		if !kv.Equal(&decoded) {
+			t.Errorf("Expected decoded KVPair to equal '%s'", kv.key)
+		}
+
+		// The decoded pair must not alias the encoded data
+		data[len(data)-1]++
+		if !kv.Equal(&decoded) {
+			t.Errorf("Expected decoded KVPair not to alias its input")
+		}
+	}
+
+	// Invalid pairs can't be marshaled
+	kv := NewKVPair([]byte("userID123"), nil, 0)
+	if _, err := kv.MarshalBinary(); err != errors.ErrKeyNotValid {
+		t.Errorf("Expected 'ErrKeyNotValid' error, got: %v", err)
+	}
+}
+
+// Test case for the AppendEncode and Decode methods
+func TestMarshalBinaryClampsTime(t *testing.T) {
+	tests := []*KVPair{
+		NewKVPair([]byte("userID123"), []byte("John Doe"), time.Duration(math.MaxInt64)),
+		Restore([]byte("userID123"), []byte("John Doe"), time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(1500, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	for _, kv := range tests {
+		data, err := kv.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Expected successful marshal, got error: %v", err)
+		}
+		if len(data) != kv.EncodedSize() {
+			t.Errorf("Expected %d encoded bytes, got %d", kv.EncodedSize(), len(data))
+		}
+
+		var decoded KVPair
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Expected successful unmarshal, got error: %v", err)
+		}
+		if decoded.IsExpired() {
+			t.Errorf("Expected decoded KVPair not to be expired, expires at %v", decoded.expiration)
+		}
+		if !decoded.expiration.Equal(maxTime) {
+			t.Errorf("Expected expiration clamped to %v, got %v", maxTime, decoded.expiration)
+		}
+	}
+
+	var decoded KVPair
+	data, _ := tests[1].MarshalBinary()
+	decoded.UnmarshalBinary(data)
+	if !decoded.updatedAt.Equal(minTime) {
+		t.Errorf("Expected update time clamped to %v, got %v", minTime, decoded.updatedAt)
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	first := NewKVPair([]byte("userID123"), []byte("John Doe"), time.Minute*5)
+	second := NewTombstone([]byte("sessionToken"))
+
+	buf := make([]byte, 0, first.EncodedSize()+second.EncodedSize())
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = second.AppendEncode(first.AppendEncode(buf[:0]))
+	})
+	if allocs != 0 {
+		t.Errorf("Expected AppendEncode not to allocate, got %v allocations", allocs)
+	}
+
+	// Decode consecutive pairs from a single buffer
+	var kv KVPair
+	n, err := kv.Decode(buf)
+	if err != nil || !kv.Equal(first) {
+		t.Fatalf("Expected to decode the first pair, got error: %v", err)
+	}
+	m, err := kv.Decode(buf[n:])
+	if err != nil || !kv.Equal(second) || !kv.IsTombstone() {
+		t.Fatalf("Expected to decode the second pair, got error: %v", err)
+	}
+	if n+m != len(buf) {
+		t.Errorf("Expected to consume %d bytes, got %d", len(buf), n+m)
+	}
+}
+
+// Test case for decoding malformed data
+func TestUnmarshalBinaryCorrupted(t *testing.T) {
+	data, _ := NewKVPair([]byte("userID123"), []byte("John Doe"), time.Minute*5).MarshalBinary()
+
+	// Every truncation must fail
+	for i := 0; i < len(data); i++ {
+		var kv KVPair
+		err := kv.UnmarshalBinary(data[:i])
+		var ce *errors.CorruptionError
+		if !stderrors.As(err, &ce) || !stderrors.Is(err, errors.ErrCorruption) {
+			t.Errorf("Expected corruption error for %d bytes, got: %v", i, err)
+		}
+	}
+
+	var kv KVPair
+	if err := kv.UnmarshalBinary(append(data, 0)); !stderrors.Is(err, errors.ErrCorruption) {
+		t.Errorf("Expected corruption error for trailing bytes, got: %v", err)
+	}
+
+	bad := append([]byte(nil), data...)
+	bad[0] = 0xff
+	if err := kv.UnmarshalBinary(bad); !stderrors.Is(err, errors.ErrCorruption) {
+		t.Errorf("Expected corruption error for invalid flags, got: %v", err)
+	}
+
+	// A tombstone carrying a value
+	bad = append([]byte(nil), data...)
+	bad[0] = byte(KindDelete) | flagExpiration
+	if err := kv.UnmarshalBinary(bad); !stderrors.Is(err, errors.ErrCorruption) {
+		t.Errorf("Expected corruption error for a tombstone with a value, got: %v", err)
+	}
+}
+
+// Fuzz test for decoder robustness against arbitrary input
+func FuzzUnmarshalBinary(f *testing.F) {
+	for _, kv := range []*KVPair{
+		NewKVPair([]byte("userID123"), []byte("John Doe"), time.Minute*5),
+		NewKVPair([]byte("k"), []byte{0x00}, 0),
+		NewTombstone([]byte("deletedKey")),
+	} {
+		data, _ := kv.MarshalBinary()
+		f.Add(data)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var kv KVPair
+		if err := kv.UnmarshalBinary(data); err != nil {
+			if !stderrors.Is(err, errors.ErrCorruption) {
+				t.Fatalf("Expected corruption error, got: %v", err)
+			}
+			return
+		}
+
+		// Whatever decodes must survive a round trip
+		encoded, err := kv.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Expected decoded KVPair to marshal, got error: %v", err)
+		}
+		var again KVPair
+		if err := again.UnmarshalBinary(encoded); err != nil || !kv.Equal(&again) {
+			t.Fatalf("Expected round trip to preserve the KVPair, got error: %v", err)
+		}
+	})
+}
+
+// Fuzz test for encoding round trips of arbitrary pairs
+func FuzzMarshalBinary(f *testing.F) {
+	f.Add([]byte("userID123"), []byte("John Doe"), int64(time.Minute), uint64(1), false)
+	f.Add([]byte("deletedKey"), []byte{}, int64(0), uint64(1<<63), true)
+
+	f.Fuzz(func(t *testing.T, key, value []byte, ttl int64, seqNum uint64, tombstone bool) {
+		kv := NewKVPair(key, value, time.Duration(ttl))
+		if tombstone {
+			kv = NewTombstone(key)
+		}
+		kv.SetSeqNum(seqNum)
+
+		data, err := kv.MarshalBinary()
+		if !kv.IsValid() {
+			if err == nil {
+				t.Fatalf("Expected invalid KVPair not to marshal")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Expected successful marshal, got error: %v", err)
+		}
+
+		// Expirations past the year 2262 are clamped
+		want := *kv
+		if !kv.expiration.IsZero() {
+			want.expiration = time.Unix(0, UnixNano(kv.expiration))
+		}
+
+		var decoded KVPair
+		if err := decoded.UnmarshalBinary(data); err != nil || !want.Equal(&decoded) {
+			t.Fatalf("Expected round trip to preserve the KVPair, got error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package memtable
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	errors "github.com/imariom/nexosdb/pkg/errors"
+	"github.com/imariom/nexosdb/pkg/iterator"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+// DefaultHashBuckets is the number of buckets of a HashLinkList when
+// Options.HashBuckets is not set.
+const DefaultHashBuckets = 1 << 16
+
+// hashNode is an entry of a HashLinkList bucket.
+type hashNode struct {
+	// key is the key of data.
+	key []byte
+
+	// data is the key/value pair.
+	data *kv.KVPair
+
+	// next is the next entry of the bucket, in key order.
+	next *hashNode
+}
+
+// HashLinkList is a Memtable that hashes keys into a fixed number of buckets,
+// each holding a linked list sorted by key. Get, Put and Delete only touch one
+// bucket, while NewIterator has to collect and sort every key.
+type HashLinkList struct {
+	// mu synchronizes access to the buckets.
+	mu sync.RWMutex
+
+	// buckets holds the head of each bucket list.
+	buckets []*hashNode
+
+	// len is the number of keys in the table, including deleted keys.
+	len int
+
+	// size is the number of key and value bytes held by the table.
+	size int64
+
+	// maxKeySize and maxValueSize are the size limits applied by Put.
+	maxKeySize, maxValueSize int
+}
+
+// NewHashLinkList creates an empty HashLinkList configured by opts.
+func NewHashLinkList(opts Options) *HashLinkList {
+	n := opts.HashBuckets
+	if n <= 0 {
+		n = DefaultHashBuckets
+	}
+
+	return &HashLinkList{
+		buckets:      make([]*hashNode, n),
+		maxKeySize:   opts.MaxKeySize,
+		maxValueSize: opts.MaxValueSize,
+	}
+}
+
+// Put inserts a key/value pair or replaces it if the key already exists.
+func (h *HashLinkList) Put(pair *kv.KVPair) error {
+	if err := pair.CheckSize(h.maxKeySize, h.maxValueSize); err != nil {
+		return err
+	}
+
+	p, err := pair.Clone()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.put(p)
+	return nil
+}
+
+// Get returns a deep copy of the key/value pair identified by key.
+func (h *HashLinkList) Get(key []byte) (*kv.KVPair, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for n := *h.bucket(key); n != nil; n = n.next {
+		if cmp := bytes.Compare(key, n.key); cmp == 0 && !n.data.IsTombstone() {
+			pair, err := n.data.Clone()
+			if err != nil {
+				return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: err}
+			}
+			return pair, nil
+		} else if cmp <= 0 {
+			break
+		}
+	}
+	return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: errors.ErrKeyNotFound}
+}
+
+// Delete marks the key as deleted by recording a tombstone for it, even when
+// the key is not in the table.
+func (h *HashLinkList) Delete(key []byte) error {
+	if err := kv.CheckSize(key, nil, h.maxKeySize, h.maxValueSize); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.put(kv.NewTombstone(append([]byte(nil), key...)))
+	return nil
+}
+
+// NewIterator returns an iterator over a sorted snapshot of the non expired,
+// non deleted key/value pairs of the table.
+func (h *HashLinkList) NewIterator() iterator.Iterator {
+	h.mu.RLock()
+	nodes := make([]*hashNode, 0, h.len)
+	for _, n := range h.buckets {
+		for ; n != nil; n = n.next {
+			if !n.data.IsExpired() && !n.data.IsTombstone() {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].key, nodes[j].key) < 0
+	})
+
+	keys := make([][]byte, len(nodes))
+	pairs := make([]*kv.KVPair, len(nodes))
+	for i, n := range nodes {
+		keys[i], pairs[i] = n.key, n.data
+	}
+	return iterator.NewSliceIterator(keys, pairs)
+}
+
+// ApproximateSize returns the number of key and value bytes held by the table.
+func (h *HashLinkList) ApproximateSize() int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.size
+}
+
+// Len returns the number of keys in the table, including deleted keys.
+func (h *HashLinkList) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.len
+}
+
+// put links p into its bucket, replacing the current pair of its key if any.
+// It must be called with the write lock held.
+func (h *HashLinkList) put(p *kv.KVPair) {
+	key, _ := p.Key()
+
+	link := h.bucket(key)
+	for n := *link; n != nil; n = *link {
+		cmp := bytes.Compare(key, n.key)
+		if cmp == 0 {
+			h.size += int64(p.Size() - n.data.Size())
+			n.data = p
+			return
+		} else if cmp < 0 {
+			break
+		}
+		link = &n.next
+	}
+
+	*link = &hashNode{key: key, data: p, next: *link}
+	h.len++
+	h.size += int64(p.Size())
+}
+
+// bucket returns a pointer to the head of the bucket key hashes to.
+func (h *HashLinkList) bucket(key []byte) **hashNode {
+	f := fnv.New64a()
+	f.Write(key)
+	return &h.buckets[f.Sum64()%uint64(len(h.buckets))]
+}
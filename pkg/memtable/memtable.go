@@ -0,0 +1,128 @@
+// Package memtable defines the Memtable interface implemented by the
+// in-memory tables that buffer writes, and lets callers pick an
+// implementation through Options.
+package memtable
+
+import (
+	"fmt"
+
+	"github.com/imariom/nexosdb/pkg/bst"
+	"github.com/imariom/nexosdb/pkg/iterator"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+	"github.com/imariom/nexosdb/pkg/skiplist"
+)
+
+// Memtable is an in-memory table of key/value pairs.
+type Memtable interface {
+	// Put inserts a key/value pair or updates it if the key already exists.
+	// Putting a tombstone, see kvpair.NewTombstone, deletes the key like
+	// Delete does. The sequence number of the pair is kept.
+	Put(pair *kv.KVPair) error
+
+	// Get returns a deep copy of the key/value pair identified by key.
+	// Missing, deleted and expired keys fail with an *errors.KeyError.
+	Get(key []byte) (*kv.KVPair, error)
+
+	// Delete marks key as deleted. Every implementation records the
+	// deletion as a tombstone, even when the key is not in the memtable, so
+	// that it shadows older versions of the key stored elsewhere. Tombstones
+	// count towards Len and ApproximateSize. The key is checked with
+	// kvpair.CheckSize like on Put.
+	Delete(key []byte) error
+
+	// NewIterator returns an iterator over the non expired key/value pairs,
+	// in key order.
+	NewIterator() iterator.Iterator
+
+	// ApproximateSize returns an estimate, in bytes, of the memory held by
+	// the memtable, to decide when to flush it. It counts at least every key
+	// and value held.
+	ApproximateSize() int64
+
+	// Len returns the number of entries held, including tombstones.
+	// Implementations that keep overwritten values count them as well.
+	Len() int
+}
+
+var (
+	_ Memtable = (*bst.BST)(nil)
+	_ Memtable = (*skiplist.SkipList)(nil)
+	_ Memtable = (*HashLinkList)(nil)
+	_ Memtable = (*Vector)(nil)
+)
+
+// Kind selects a Memtable implementation.
+type Kind int
+
+const (
+	// KindSkipList is a concurrent skip list allocated in a fixed size
+	// arena. It is the default and fits most workloads.
+	KindSkipList Kind = iota
+
+	// KindBST is a balanced binary search tree guarded by a single lock.
+	KindBST
+
+	// KindHashLinkList is a hash table of sorted linked lists. Point lookups
+	// are fast but iteration has to sort every key.
+	KindHashLinkList
+
+	// KindVector is an append-only vector. Writes are cheapest, but Get scans
+	// every entry and iteration has to sort. It is meant for bulk loading.
+	KindVector
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindSkipList:
+		return "skiplist"
+	case KindBST:
+		return "bst"
+	case KindHashLinkList:
+		return "hashlinklist"
+	case KindVector:
+		return "vector"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Options configures the Memtable created by New.
+type Options struct {
+	// Kind is the implementation to use.
+	Kind Kind
+
+	// MaxKeySize is the maximum key length accepted by Put.
+	// Zero means kvpair.MaxKeySize.
+	MaxKeySize int
+
+	// MaxValueSize is the maximum value length accepted by Put.
+	// Zero means kvpair.MaxValueSize.
+	MaxValueSize int
+
+	// ArenaSize is the size in bytes of the arena backing a KindSkipList
+	// memtable, which bounds how much it can hold. Zero means
+	// skiplist.DefaultArenaSize, smaller sizes are raised to
+	// skiplist.MinArenaSize.
+	ArenaSize int
+
+	// HashBuckets is the number of buckets of a KindHashLinkList memtable.
+	// Zero means DefaultHashBuckets.
+	HashBuckets int
+}
+
+// New creates an empty Memtable of the kind selected by opts.
+func New(opts Options) (Memtable, error) {
+	switch opts.Kind {
+	case KindSkipList:
+		s := skiplist.New(opts.ArenaSize)
+		s.MaxKeySize, s.MaxValueSize = opts.MaxKeySize, opts.MaxValueSize
+		return s, nil
+	case KindBST:
+		return &bst.BST{MaxKeySize: opts.MaxKeySize, MaxValueSize: opts.MaxValueSize}, nil
+	case KindHashLinkList:
+		return NewHashLinkList(opts), nil
+	case KindVector:
+		return NewVector(opts), nil
+	}
+	return nil, fmt.Errorf("memtable: unknown kind %v", opts.Kind)
+}
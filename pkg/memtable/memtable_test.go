@@ -0,0 +1,254 @@
+package memtable
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	errors "github.com/imariom/nexosdb/pkg/errors"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+var kinds = []Kind{KindSkipList, KindBST, KindHashLinkList, KindVector}
+
+// newMemtable creates a memtable of kind k, failing the test on error.
+func newMemtable(t *testing.T, k Kind) Memtable {
+	t.Helper()
+
+	m, err := New(Options{Kind: k, HashBuckets: 16})
+	if err != nil {
+		t.Fatalf("Expected memtable of kind %v, got %v", k, err)
+	}
+	return m
+}
+
+func TestMemtable_PutAndGet(t *testing.T) {
+	for _, k := range kinds {
+		t.Run(k.String(), func(t *testing.T) {
+			m := newMemtable(t, k)
+			for i := 0; i < 100; i++ {
+				key := []byte(fmt.Sprintf("key-%03d", i))
+				if err := m.Put(kv.NewKVPair(key, []byte("v1"), 0)); err != nil {
+					t.Fatalf("Expected put to succeed, got %v", err)
+				}
+			}
+			for i := 0; i < 100; i += 2 {
+				key := []byte(fmt.Sprintf("key-%03d", i))
+				m.Put(kv.NewKVPair(key, []byte("v2"), 0))
+			}
+
+			for i := 0; i < 100; i++ {
+				pair, err := m.Get([]byte(fmt.Sprintf("key-%03d", i)))
+				if err != nil {
+					t.Fatalf("Expected KVPair, but got %v", err)
+				}
+
+				want := "v1"
+				if i%2 == 0 {
+					want = "v2"
+				}
+				if value, _ := pair.Value(); string(value) != want {
+					t.Errorf("Expected '%s' value, got '%s'", want, value)
+				}
+			}
+
+			if _, err := m.Get([]byte("missing")); !stderrors.Is(err, errors.ErrKeyNotFound) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyNotFound, err)
+			}
+
+			// An expired key can be overwritten, keeping the new update time
+			m.Put(kv.NewKVPair([]byte("expiring"), []byte("v1"), time.Millisecond))
+			time.Sleep(2 * time.Millisecond)
+			var ke *errors.KeyError
+			if _, err := m.Get([]byte("expiring")); !stderrors.As(err, &ke) || string(ke.Key) != "expiring" || !stderrors.Is(err, errors.ErrKeyExpired) {
+				t.Errorf("Expected *errors.KeyError wrapping '%v', got %v", errors.ErrKeyExpired, err)
+			}
+			if err := m.Put(kv.Restore([]byte("expiring"), []byte("v2"), time.Time{}, time.Unix(200, 0))); err != nil {
+				t.Fatalf("Expected put over an expired key to succeed, got %v", err)
+			}
+			pair, err := m.Get([]byte("expiring"))
+			if err != nil {
+				t.Fatalf("Expected KVPair, but got %v", err)
+			}
+			if value, _ := pair.Value(); string(value) != "v2" {
+				t.Errorf("Expected 'v2' value, got '%s'", value)
+			}
+			if updatedAt, _ := pair.UpdatedAt(); !updatedAt.Equal(time.Unix(200, 0)) {
+				t.Errorf("Expected update time %v, got %v", time.Unix(200, 0), updatedAt)
+			}
+
+			if m.Len() < 100 {
+				t.Errorf("Expected at least 100 entries, got %d", m.Len())
+			}
+			if m.ApproximateSize() < 100*int64(len("key-000v1")) {
+				t.Errorf("Expected size to account for every key and value, got %d", m.ApproximateSize())
+			}
+		})
+	}
+}
+
+func TestMemtable_Delete(t *testing.T) {
+	for _, k := range kinds {
+		t.Run(k.String(), func(t *testing.T) {
+			m := newMemtable(t, k)
+			m.Put(kv.NewKVPair([]byte("userID123"), []byte("John Doe"), 0))
+			m.Put(kv.NewKVPair([]byte("email"), []byte("jane.doe@example.com"), 0))
+
+			if err := m.Delete([]byte("userID123")); err != nil {
+				t.Fatalf("Expected delete to succeed, got %v", err)
+			}
+			if err := m.Delete([]byte("missing")); err != nil {
+				t.Fatalf("Expected delete of a missing key to succeed, got %v", err)
+			}
+			if err := m.Delete(nil); !stderrors.Is(err, errors.ErrKeyRequired) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyRequired, err)
+			}
+
+			// Both tombstones are kept, shadowing older versions elsewhere
+			if m.Len() < 3 {
+				t.Errorf("Expected tombstones to count towards Len, got %d", m.Len())
+			}
+			if _, err := m.Get([]byte("missing")); !stderrors.Is(err, errors.ErrKeyNotFound) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyNotFound, err)
+			}
+
+			if _, err := m.Get([]byte("userID123")); !stderrors.Is(err, errors.ErrKeyNotFound) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyNotFound, err)
+			}
+			if _, err := m.Get([]byte("email")); err != nil {
+				t.Errorf("Expected other keys to survive delete, got %v", err)
+			}
+
+			m.Put(kv.NewKVPair([]byte("userID123"), []byte("Jane Doe"), 0))
+			if _, err := m.Get([]byte("userID123")); err != nil {
+				t.Errorf("Expected key to be readable after re-insert, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMemtable_PutTombstone(t *testing.T) {
+	for _, k := range kinds {
+		t.Run(k.String(), func(t *testing.T) {
+			m := newMemtable(t, k)
+			for _, key := range []string{"a", "b", "c"} {
+				pair := kv.NewKVPair([]byte(key), []byte("value"), 0)
+				pair.SetSeqNum(1)
+				m.Put(pair)
+			}
+
+			tombstone := kv.NewTombstone([]byte("b"))
+			tombstone.SetSeqNum(2)
+			if err := m.Put(tombstone); err != nil {
+				t.Fatalf("Expected put of tombstone to succeed, got %v", err)
+			}
+			if err := m.Put(kv.NewTombstone([]byte("missing"))); err != nil {
+				t.Fatalf("Expected put of tombstone to succeed, got %v", err)
+			}
+
+			if _, err := m.Get([]byte("b")); !stderrors.Is(err, errors.ErrKeyNotFound) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyNotFound, err)
+			}
+			if _, err := m.Get([]byte("missing")); !stderrors.Is(err, errors.ErrKeyNotFound) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyNotFound, err)
+			}
+
+			pair, err := m.Get([]byte("a"))
+			if err != nil {
+				t.Fatalf("Expected KVPair, but got %v", err)
+			}
+			if pair.SeqNum() != 1 {
+				t.Errorf("Expected sequence number 1, got %d", pair.SeqNum())
+			}
+
+			var keys []string
+			it := m.NewIterator()
+			for it.SeekToFirst(); it.Valid(); it.Next() {
+				keys = append(keys, string(it.Key()))
+			}
+			it.Close()
+			if fmt.Sprint(keys) != "[a c]" {
+				t.Errorf("Expected keys [a c], got %v", keys)
+			}
+		})
+	}
+}
+
+func TestMemtable_Iterator(t *testing.T) {
+	for _, k := range kinds {
+		t.Run(k.String(), func(t *testing.T) {
+			m := newMemtable(t, k)
+			for _, i := range []int{5, 3, 9, 1, 7, 3} {
+				m.Put(kv.NewKVPair([]byte(fmt.Sprintf("key-%d", i)), []byte("value"), 0))
+			}
+			m.Put(kv.NewKVPair([]byte("key-4"), []byte("value"), time.Millisecond))
+			m.Delete([]byte("key-9"))
+			time.Sleep(time.Millisecond * 5)
+
+			it := m.NewIterator()
+			defer it.Close()
+
+			var got []string
+			for it.SeekToFirst(); it.Valid(); it.Next() {
+				pair, err := it.Pair()
+				if err != nil {
+					t.Fatalf("Expected pair, got %v", err)
+				}
+				if key, _ := pair.Key(); !bytes.Equal(key, it.Key()) {
+					t.Errorf("Expected pair key '%s' to match iterator key '%s'", key, it.Key())
+				}
+				got = append(got, string(it.Key()))
+			}
+			if want := "[key-1 key-3 key-5 key-7]"; fmt.Sprint(got) != want {
+				t.Errorf("Expected keys %s, got %v", want, got)
+			}
+
+			it.Seek([]byte("key-4"))
+			if !it.Valid() || string(it.Key()) != "key-5" {
+				t.Errorf("Expected Seek to land on 'key-5'")
+			}
+			it.Seek([]byte("key-8"))
+			if it.Valid() {
+				t.Errorf("Expected Seek past the last key to be invalid, got '%s'", it.Key())
+			}
+		})
+	}
+}
+
+func TestMemtable_SizeLimits(t *testing.T) {
+	for _, k := range kinds {
+		t.Run(k.String(), func(t *testing.T) {
+			m, _ := New(Options{Kind: k, MaxKeySize: 4, MaxValueSize: 4})
+
+			err := m.Put(kv.NewKVPair([]byte("userID123"), []byte("John"), 0))
+			if !stderrors.Is(err, errors.ErrKeyTooLarge) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrKeyTooLarge, err)
+			}
+			err = m.Put(kv.NewKVPair([]byte("user"), []byte("John Doe"), 0))
+			if !stderrors.Is(err, errors.ErrValueTooLarge) {
+				t.Errorf("Expected '%v' error, got %v", errors.ErrValueTooLarge, err)
+			}
+		})
+	}
+}
+
+func TestNew_SmallArena(t *testing.T) {
+	m, err := New(Options{ArenaSize: 64})
+	if err != nil {
+		t.Fatalf("Expected memtable, got %v", err)
+	}
+	if err := m.Put(kv.NewKVPair([]byte("userID123"), []byte("John Doe"), 0)); err != nil {
+		t.Fatalf("Expected put to succeed, got %v", err)
+	}
+	if _, err := m.Get([]byte("userID123")); err != nil {
+		t.Errorf("Expected KVPair, but got %v", err)
+	}
+}
+
+func TestNew_UnknownKind(t *testing.T) {
+	if _, err := New(Options{Kind: Kind(42)}); err == nil {
+		t.Errorf("Expected error for an unknown kind")
+	}
+}
@@ -0,0 +1,146 @@
+package memtable
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	errors "github.com/imariom/nexosdb/pkg/errors"
+	"github.com/imariom/nexosdb/pkg/iterator"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+// vectorEntry is a write recorded by a Vector.
+type vectorEntry struct {
+	// key is the key written.
+	key []byte
+
+	// data is the key/value pair written, a tombstone for a deletion.
+	data *kv.KVPair
+}
+
+// Vector is a Memtable that appends every write to a slice. Writes are as
+// cheap as they can be, but Get scans the entries from newest to oldest and
+// NewIterator sorts them, so it suits bulk loading followed by a single scan.
+type Vector struct {
+	// mu synchronizes access to entries.
+	mu sync.RWMutex
+
+	// entries holds every write, oldest first.
+	entries []vectorEntry
+
+	// size is the number of key and value bytes written.
+	size int64
+
+	// maxKeySize and maxValueSize are the size limits applied by Put.
+	maxKeySize, maxValueSize int
+}
+
+// NewVector creates an empty Vector configured by opts.
+func NewVector(opts Options) *Vector {
+	return &Vector{
+		maxKeySize:   opts.MaxKeySize,
+		maxValueSize: opts.MaxValueSize,
+	}
+}
+
+// Put appends a key/value pair, shadowing any older write of the same key.
+func (v *Vector) Put(pair *kv.KVPair) error {
+	if err := pair.CheckSize(v.maxKeySize, v.maxValueSize); err != nil {
+		return err
+	}
+
+	p, err := pair.Clone()
+	if err != nil {
+		return err
+	}
+	key, _ := p.Key()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.entries = append(v.entries, vectorEntry{key: key, data: p})
+	v.size += int64(p.Size())
+	return nil
+}
+
+// Get returns a deep copy of the most recent key/value pair written for key.
+func (v *Vector) Get(key []byte) (*kv.KVPair, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for i := len(v.entries) - 1; i >= 0; i-- {
+		if e := v.entries[i]; bytes.Equal(key, e.key) {
+			if e.data.IsTombstone() {
+				break
+			}
+			pair, err := e.data.Clone()
+			if err != nil {
+				return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: err}
+			}
+			return pair, nil
+		}
+	}
+	return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: errors.ErrKeyNotFound}
+}
+
+// Delete appends a deletion of key, shadowing any older write of it.
+func (v *Vector) Delete(key []byte) error {
+	if err := kv.CheckSize(key, nil, v.maxKeySize, v.maxValueSize); err != nil {
+		return err
+	}
+
+	key = append([]byte(nil), key...)
+	t := kv.NewTombstone(key)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.entries = append(v.entries, vectorEntry{key: key, data: t})
+	v.size += int64(t.Size())
+	return nil
+}
+
+// NewIterator returns an iterator over the latest non expired, non deleted
+// key/value pairs, sorted by key.
+func (v *Vector) NewIterator() iterator.Iterator {
+	v.mu.RLock()
+	entries := append([]vectorEntry(nil), v.entries...)
+	v.mu.RUnlock()
+
+	// A stable sort keeps writes of the same key oldest first, so the last
+	// one of each run is the latest.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	var keys [][]byte
+	var pairs []*kv.KVPair
+	for i, e := range entries {
+		if i+1 < len(entries) && bytes.Equal(e.key, entries[i+1].key) {
+			continue
+		}
+		if e.data.IsTombstone() || e.data.IsExpired() {
+			continue
+		}
+		keys = append(keys, e.key)
+		pairs = append(pairs, e.data)
+	}
+	return iterator.NewSliceIterator(keys, pairs)
+}
+
+// ApproximateSize returns the number of key and value bytes written,
+// including overwritten values and deletions.
+func (v *Vector) ApproximateSize() int64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.size
+}
+
+// Len returns the number of writes recorded, including overwrites and
+// deletions.
+func (v *Vector) Len() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.entries)
+}
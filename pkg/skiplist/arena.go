@@ -0,0 +1,172 @@
+package skiplist
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	errors "github.com/imariom/nexosdb/pkg/errors"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+const (
+	// DefaultArenaSize is the arena size used when New is given zero.
+	DefaultArenaSize = 64 << 20
+
+	// MinArenaSize is the smallest arena size supported. It leaves room for
+	// the head node, which spans every level, and a few keys.
+	MinArenaSize = 1 << 12
+
+	// MaxArenaSize is the largest arena size supported, since arena offsets
+	// are 32 bits wide. It is typed so the package builds where int is 32
+	// bits, where New caps sizes lower so they stay within an int.
+	MaxArenaSize int64 = 1<<32 - 1<<20
+
+	// nodeAlign is the alignment of nodes in the arena, required by the
+	// atomic 64 bit value field.
+	nodeAlign = 8
+
+	// maxNodeSize is the size of a node of maxHeight.
+	maxNodeSize = int(unsafe.Sizeof(node{}))
+
+	// entryHeaderSize is the size of the header preceding a value in the
+	// arena: one flags byte, the sequence number, then expiration and
+	// updatedAt as unix nanos.
+	entryHeaderSize = 1 + 8 + 8 + 8
+
+	// flagDeleted marks an entry as a deletion.
+	flagDeleted = 1 << 0
+)
+
+// arena is a fixed size, lock free bump allocator holding every node, key
+// and value of a skip list. The buffer holds no Go pointers, so the garbage
+// collector never has to scan it, and nodes refer to each other by offset.
+// Offset zero is never handed out and stands for nil.
+type arena struct {
+	// n is the offset of the next free byte.
+	n atomic.Uint32
+
+	// buf is the memory of the arena. It is maxNodeSize bytes longer than
+	// the arena size, so a node pointer never straddles its end.
+	buf []byte
+}
+
+// clampArenaSize returns the arena size New uses when given size.
+func clampArenaSize(size int) int {
+	if size <= 0 {
+		size = DefaultArenaSize
+	}
+	size = max(size, MinArenaSize)
+	size = int(min(int64(size), MaxArenaSize))
+	// newArena adds room for a trailing node, which must not overflow.
+	return min(size, math.MaxInt-maxNodeSize-7)
+}
+
+// newArena creates an arena of size bytes.
+func newArena(size int) *arena {
+	// Allocate words rather than bytes so the buffer is 8 byte aligned.
+	words := make([]uint64, (size+maxNodeSize+7)/8)
+	a := &arena{buf: unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), len(words)*8)}
+	a.n.Store(1)
+	return a
+}
+
+// size returns the number of bytes allocated from the arena.
+func (a *arena) size() int64 {
+	return int64(a.n.Load())
+}
+
+// capacity returns the number of bytes that can be allocated from the arena.
+func (a *arena) capacity() uint32 {
+	return uint32(len(a.buf) - maxNodeSize)
+}
+
+// alloc reserves size bytes aligned to align, a power of two, and returns
+// their offset. It fails with errors.ErrMemtableFull once the arena is full.
+func (a *arena) alloc(size, align uint32) (uint32, error) {
+	padded := uint64(size) + uint64(align) - 1
+	for {
+		n := a.n.Load()
+		if uint64(n)+padded > uint64(a.capacity()) {
+			return 0, errors.ErrMemtableFull
+		}
+		if a.n.CompareAndSwap(n, n+uint32(padded)) {
+			return (n + align - 1) &^ (align - 1), nil
+		}
+	}
+}
+
+// putKey copies key into the arena and returns its offset.
+func (a *arena) putKey(key []byte) (uint32, error) {
+	off, err := a.alloc(uint32(len(key)), 1)
+	if err != nil {
+		return 0, err
+	}
+	copy(a.buf[off:], key)
+	return off, nil
+}
+
+// getKey returns the key stored at off.
+func (a *arena) getKey(off, size uint32) []byte {
+	return a.buf[off : off+size : off+size]
+}
+
+// putEntry encodes an entry into the arena and returns its offset and size
+// packed with packEntry.
+func (a *arena) putEntry(flags byte, seqNum uint64, value []byte, expiration, updatedAt time.Time) (uint64, error) {
+	size := uint32(entryHeaderSize + len(value))
+	off, err := a.alloc(size, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	b := a.buf[off : off+size]
+	b[0] = flags
+	binary.LittleEndian.PutUint64(b[1:], seqNum)
+	binary.LittleEndian.PutUint64(b[9:], uint64(kv.UnixNano(expiration)))
+	binary.LittleEndian.PutUint64(b[17:], uint64(kv.UnixNano(updatedAt)))
+	copy(b[entryHeaderSize:], value)
+	return packEntry(off, size), nil
+}
+
+// getEntry decodes the entry identified by packed.
+func (a *arena) getEntry(packed uint64) (flags byte, seqNum uint64, value []byte, expiration, updatedAt time.Time) {
+	off, size := unpackEntry(packed)
+	b := a.buf[off : off+size : off+size]
+	return b[0], binary.LittleEndian.Uint64(b[1:]), b[entryHeaderSize:],
+		kv.FromUnixNano(int64(binary.LittleEndian.Uint64(b[9:]))),
+		kv.FromUnixNano(int64(binary.LittleEndian.Uint64(b[17:])))
+}
+
+// newNode allocates a node of the given height and returns its offset.
+func (a *arena) newNode(height int) (uint32, error) {
+	// Only the first height levels of the tower are allocated.
+	unused := (maxHeight - height) * int(unsafe.Sizeof(atomic.Uint32{}))
+	off, err := a.alloc(uint32(maxNodeSize-unused), nodeAlign)
+	if err != nil {
+		return 0, err
+	}
+	a.getNode(off).height = uint16(height)
+	return off, nil
+}
+
+// getNode returns the node at off, or nil if off is zero.
+func (a *arena) getNode(off uint32) *node {
+	if off == 0 {
+		return nil
+	}
+	return (*node)(unsafe.Pointer(&a.buf[off]))
+}
+
+// packEntry packs the offset and size of an entry into a single word, so
+// both can be swapped atomically.
+func packEntry(off, size uint32) uint64 {
+	return uint64(off)<<32 | uint64(size)
+}
+
+// unpackEntry is the inverse of packEntry.
+func unpackEntry(packed uint64) (off, size uint32) {
+	return uint32(packed >> 32), uint32(packed)
+}
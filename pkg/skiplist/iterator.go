@@ -0,0 +1,60 @@
+package skiplist
+
+import (
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+// Iterator iterates over the non expired key/value pairs of a SkipList.
+type Iterator struct {
+	// list is the skip list being iterated.
+	list *SkipList
+
+	// n is the current node, nil when the iterator is not positioned.
+	n *node
+}
+
+// SeekToFirst positions the iterator at the first pair.
+func (it *Iterator) SeekToFirst() {
+	it.n = it.list.next(it.list.arena.getNode(it.list.head), 0)
+	it.skip()
+}
+
+// Seek positions the iterator at the first pair whose key is >= key.
+func (it *Iterator) Seek(key []byte) {
+	it.n = it.list.findGreaterOrEqual(key)
+	it.skip()
+}
+
+// Valid reports whether the iterator is positioned at a pair.
+func (it *Iterator) Valid() bool {
+	return it.n != nil
+}
+
+// Next moves the iterator to the next pair.
+func (it *Iterator) Next() {
+	it.n = it.list.next(it.n, 0)
+	it.skip()
+}
+
+// Key returns the key of the current pair.
+func (it *Iterator) Key() []byte {
+	return it.list.key(it.n)
+}
+
+// Pair returns a deep copy of the current pair.
+func (it *Iterator) Pair() (*kv.KVPair, error) {
+	return it.list.pair(it.n)
+}
+
+// Close releases the iterator.
+func (it *Iterator) Close() error {
+	it.n = nil
+	return nil
+}
+
+// skip advances the iterator past deleted and expired keys.
+func (it *Iterator) skip() {
+	for it.n != nil && !it.list.live(it.n) {
+		it.n = it.list.next(it.n, 0)
+	}
+}
@@ -0,0 +1,366 @@
+// Package skiplist implements a concurrent skip list of key/value pairs,
+// ordered by key, to be used as a memtable.
+//
+// Readers never take locks and writers insert with compare-and-swap, so
+// concurrent readers and writers do not serialize on a single mutex. Nodes,
+// keys and values all live in a fixed size arena and refer to each other by
+// offset, so a skip list is a single allocation the garbage collector does
+// not need to scan. Once the arena is full, writes fail with
+// errors.ErrMemtableFull and the list should be flushed.
+package skiplist
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	errors "github.com/imariom/nexosdb/pkg/errors"
+	"github.com/imariom/nexosdb/pkg/iterator"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+const (
+	// maxHeight is the maximum number of levels of the skip list.
+	maxHeight = 20
+
+	// pBranch is the probability, scaled to math.MaxUint32, that a node
+	// reaching one level also reaches the next.
+	pBranch = math.MaxUint32 / 4
+
+	// pairOverhead is the most arena space a key/value pair takes besides
+	// its key and value: a node of maxHeight with alignment padding, and
+	// an entry header.
+	pairOverhead = maxNodeSize + nodeAlign - 1 + entryHeaderSize
+)
+
+// node represents a single key in the skip list. Nodes are allocated in the
+// arena and must not hold Go pointers.
+type node struct {
+	// entry is the arena offset and size of the current entry of the key,
+	// packed with packEntry. Updating the key atomically replaces it.
+	entry atomic.Uint64
+
+	// keyOffset is the arena offset of the key.
+	keyOffset uint32
+
+	// keySize is the length of the key.
+	keySize uint32
+
+	// height is the number of levels the node is part of.
+	height uint16
+
+	// tower holds the arena offset of the next node on each level the node
+	// is part of. Only the first height entries are allocated.
+	tower [maxHeight]atomic.Uint32
+}
+
+// SkipList represents the skip list. It must be created with New.
+type SkipList struct {
+	// arena holds the nodes, keys and values of the list.
+	arena *arena
+
+	// head is the sentinel node preceding every key, on all levels.
+	head uint32
+
+	// height is the number of levels currently in use.
+	height atomic.Int32
+
+	// len is the number of nodes in the list, including deleted keys.
+	len atomic.Int64
+
+	// room is the number of arena bytes left once the head is allocated.
+	room int
+
+	// MaxKeySize is the maximum key length accepted by Insert.
+	// Zero means kvpair.MaxKeySize.
+	MaxKeySize int
+
+	// MaxValueSize is the maximum value length accepted by Insert.
+	// Zero means kvpair.MaxValueSize.
+	MaxValueSize int
+}
+
+// New creates an empty skip list backed by an arena of arenaSize bytes.
+// Zero means DefaultArenaSize. Other sizes are raised to MinArenaSize or
+// capped to MaxArenaSize, and to what an int can address on 32 bit targets.
+func New(arenaSize int) *SkipList {
+	s := &SkipList{arena: newArena(clampArenaSize(arenaSize))}
+	// MinArenaSize guarantees the head node fits.
+	s.head, _ = s.arena.newNode(maxHeight)
+	s.room = int(s.arena.capacity()) - int(s.arena.size())
+	s.height.Store(1)
+	return s
+}
+
+// Insert inserts a new key/value pair in the list or replaces the current
+// value of the key if it already exists. Inserting a tombstone deletes the
+// key like Delete does. Pairs too large to fit even in an
+// empty arena fail with errors.ErrKeyTooLarge or errors.ErrValueTooLarge
+// rather than errors.ErrMemtableFull, since flushing would not help.
+func (s *SkipList) Insert(pair *kv.KVPair) error {
+	if err := pair.CheckSize(s.MaxKeySize, s.MaxValueSize); err != nil {
+		return err
+	}
+
+	key, err := pair.Key()
+	if err != nil {
+		return err
+	}
+	value, _ := pair.Value()
+	if err := s.checkFits(len(key), len(value)); err != nil {
+		return err
+	}
+	expiration, _ := pair.Expiration()
+	updatedAt, _ := pair.UpdatedAt()
+
+	var flags byte
+	if pair.IsTombstone() {
+		flags |= flagDeleted
+	}
+	entry, err := s.arena.putEntry(flags, pair.SeqNum(), value, expiration, updatedAt)
+	if err != nil {
+		return err
+	}
+	return s.put(key, entry)
+}
+
+// Put inserts or updates a key/value pair. It is the same as Insert.
+func (s *SkipList) Put(pair *kv.KVPair) error {
+	return s.Insert(pair)
+}
+
+// Get returns a deep copy of the key/value pair identified by key.
+func (s *SkipList) Get(key []byte) (*kv.KVPair, error) {
+	pair, err := s.pair(s.findNode(key))
+	if err != nil {
+		return nil, &errors.KeyError{Key: append([]byte(nil), key...), Err: err}
+	}
+	return pair, nil
+}
+
+// InOrder returns deep copies of all non expired key/value pairs, in key order.
+func (s *SkipList) InOrder() []*kv.KVPair {
+	var result []*kv.KVPair
+	for n := s.next(s.arena.getNode(s.head), 0); n != nil; n = s.next(n, 0) {
+		if s.live(n) {
+			pair, _ := s.pair(n)
+			result = append(result, pair)
+		}
+	}
+	return result
+}
+
+// Search searches for a non expired key/value pair in the list.
+func (s *SkipList) Search(key []byte) bool {
+	n := s.findNode(key)
+	return n != nil && s.live(n)
+}
+
+// Delete marks the key as deleted. The deletion is recorded even when the key
+// is not in the list, so that it shadows older versions of the key stored
+// elsewhere.
+func (s *SkipList) Delete(key []byte) error {
+	if err := kv.CheckSize(key, nil, s.MaxKeySize, s.MaxValueSize); err != nil {
+		return err
+	} else if err := s.checkFits(len(key), 0); err != nil {
+		return err
+	}
+
+	entry, err := s.arena.putEntry(flagDeleted, 0, nil, time.Time{}, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.put(key, entry)
+}
+
+// NewIterator returns an iterator over the non expired key/value pairs of the
+// list, in key order. The iterator does not block writers and observes the
+// keys they insert while it is in use.
+func (s *SkipList) NewIterator() iterator.Iterator {
+	return &Iterator{list: s}
+}
+
+// ApproximateSize returns the number of arena bytes used by the list. It
+// accounts for every node, key and value, including overwritten values and
+// deleted keys, and never exceeds the arena size.
+func (s *SkipList) ApproximateSize() int64 {
+	return s.arena.size()
+}
+
+// Len returns the number of keys in the list, including deleted keys.
+func (s *SkipList) Len() int {
+	return int(s.len.Load())
+}
+
+// checkFits fails if a pair with a key of keySize bytes and a value of
+// valueSize bytes does not fit in the arena even when it is empty.
+func (s *SkipList) checkFits(keySize, valueSize int) error {
+	room := s.room - pairOverhead
+	if keySize > room {
+		return fmt.Errorf("%w: %d bytes exceeds arena limit of %d", errors.ErrKeyTooLarge, keySize, room)
+	} else if keySize+valueSize > room {
+		return fmt.Errorf("%w: %d bytes exceeds arena limit of %d", errors.ErrValueTooLarge, valueSize, room-keySize)
+	}
+	return nil
+}
+
+// put links a node for key holding entry, or replaces the entry of the
+// existing node for key.
+func (s *SkipList) put(key []byte, entry uint64) error {
+	var prev, next [maxHeight + 1]uint32
+
+	// Find the splice of the key on every level, top down.
+	listHeight := int(s.height.Load())
+	prev[listHeight] = s.head
+	for i := listHeight - 1; i >= 0; i-- {
+		prev[i], next[i] = s.findSpliceForLevel(key, prev[i+1], i)
+		if prev[i] == next[i] {
+			s.arena.getNode(prev[i]).entry.Store(entry)
+			return nil
+		}
+	}
+
+	height := randomHeight()
+	off, err := s.arena.newNode(height)
+	if err != nil {
+		return err
+	}
+	keyOffset, err := s.arena.putKey(key)
+	if err != nil {
+		return err
+	}
+	x := s.arena.getNode(off)
+	x.keyOffset, x.keySize = keyOffset, uint32(len(key))
+	x.entry.Store(entry)
+
+	// Grow the list height if needed. Other writers may be doing the same.
+	for h := s.height.Load(); int(h) < height; h = s.height.Load() {
+		if s.height.CompareAndSwap(h, int32(height)) {
+			break
+		}
+	}
+
+	// Link the node bottom up. Once it is linked on level 0 the key is
+	// visible to readers.
+	for i := 0; i < height; i++ {
+		for {
+			if prev[i] == 0 {
+				// The level was not in use when the splice was computed.
+				prev[i], next[i] = s.findSpliceForLevel(key, s.head, i)
+			}
+
+			x.tower[i].Store(next[i])
+			if s.arena.getNode(prev[i]).tower[i].CompareAndSwap(next[i], off) {
+				break
+			}
+
+			// Another writer changed the splice, recompute it and retry.
+			prev[i], next[i] = s.findSpliceForLevel(key, prev[i], i)
+			if prev[i] == next[i] {
+				// Only possible on level 0, another writer linked the same
+				// key first.
+				s.arena.getNode(prev[i]).entry.Store(entry)
+				return nil
+			}
+		}
+	}
+	s.len.Add(1)
+	return nil
+}
+
+// findSpliceForLevel walks level from before and returns the offsets of the
+// nodes between which key belongs. If key is found, both returned offsets are
+// those of its node.
+func (s *SkipList) findSpliceForLevel(key []byte, before uint32, level int) (uint32, uint32) {
+	for {
+		next := s.arena.getNode(before).tower[level].Load()
+		if next == 0 {
+			return before, 0
+		}
+
+		cmp := bytes.Compare(key, s.key(s.arena.getNode(next)))
+		if cmp == 0 {
+			return next, next
+		} else if cmp < 0 {
+			return before, next
+		}
+		before = next
+	}
+}
+
+// findNode returns the node for key, or nil if the key is not in the list.
+func (s *SkipList) findNode(key []byte) *node {
+	n := s.findGreaterOrEqual(key)
+	if n == nil || !bytes.Equal(key, s.key(n)) {
+		return nil
+	}
+	return n
+}
+
+// findGreaterOrEqual returns the first node whose key is greater than or equal
+// to key, or nil if there is none.
+func (s *SkipList) findGreaterOrEqual(key []byte) *node {
+	x := s.arena.getNode(s.head)
+	for level := int(s.height.Load()) - 1; level >= 0; level-- {
+		for {
+			next := s.next(x, level)
+			if next == nil {
+				break
+			}
+
+			cmp := bytes.Compare(key, s.key(next))
+			if cmp == 0 {
+				return next
+			} else if cmp < 0 {
+				break
+			}
+			x = next
+		}
+	}
+	return s.next(x, 0)
+}
+
+// next returns the node following n on level, or nil if there is none.
+func (s *SkipList) next(n *node, level int) *node {
+	return s.arena.getNode(n.tower[level].Load())
+}
+
+// key returns the key of n.
+func (s *SkipList) key(n *node) []byte {
+	return s.arena.getKey(n.keyOffset, n.keySize)
+}
+
+// live reports whether the key of n is neither deleted nor expired.
+func (s *SkipList) live(n *node) bool {
+	flags, _, _, expiration, _ := s.arena.getEntry(n.entry.Load())
+	return flags&flagDeleted == 0 && (expiration.IsZero() || time.Now().Before(expiration))
+}
+
+// pair returns a deep copy of n as a KVPair. It fails if n is nil, deleted or
+// expired.
+func (s *SkipList) pair(n *node) (*kv.KVPair, error) {
+	if n == nil {
+		return nil, errors.ErrKeyNotFound
+	}
+
+	flags, seqNum, value, expiration, updatedAt := s.arena.getEntry(n.entry.Load())
+	if flags&flagDeleted != 0 {
+		return nil, errors.ErrKeyNotFound
+	}
+	pair := kv.Restore(s.key(n), value, expiration, updatedAt)
+	pair.SetSeqNum(seqNum)
+	return pair.Clone()
+}
+
+// randomHeight returns the height of a new node, following a geometric
+// distribution.
+func randomHeight() int {
+	h := 1
+	for h < maxHeight && rand.Uint32() <= pBranch {
+		h++
+	}
+	return h
+}
@@ -0,0 +1,347 @@
+package skiplist
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	errors "github.com/imariom/nexosdb/pkg/errors"
+	kv "github.com/imariom/nexosdb/pkg/kvpair"
+)
+
+var kvpairs = []struct {
+	key   []byte
+	value []byte
+	ttl   time.Duration
+}{
+	{[]byte("userID123"), []byte("John Doe"), time.Minute * 1},
+	{[]byte("sessionToken"), []byte("abc123xyz"), time.Minute * 5},
+	{[]byte("permanentUserID"), []byte("user123456"), 0},
+	{[]byte("email"), []byte("jane.doe@example.com"), time.Hour * 1},
+	{[]byte("orderID456"), []byte("Order#789456"), time.Minute * 30},
+	{[]byte("configSetting"), []byte("default"), 0},
+	{[]byte("productID"), []byte("Widget-X100"), time.Hour * 2},
+	{[]byte("binaryData"), []byte{0x0A, 0x1B, 0x2C, 0x3D}, time.Second * 45},
+	{[]byte("cartID"), []byte("CART98765"), time.Minute * 15},
+	{[]byte("apiKey"), []byte("apikey-xyz-123"), 0},
+	{[]byte("imageHeader"), []byte{0xFF, 0xD8, 0xFF, 0xE0}, time.Minute * 20},
+	{[]byte("jsonString"), []byte("{\"name\":\"John\"}"), time.Hour * 1},
+}
+
+func TestSkipList_InsertAndGet(t *testing.T) {
+	s := New(1 << 20)
+	for _, test := range kvpairs {
+		if err := s.Insert(kv.NewKVPair(test.key, test.value, test.ttl)); err != nil {
+			t.Fatalf("Expected insert to succeed, got %v", err)
+		}
+	}
+
+	for _, test := range kvpairs {
+		if !s.Search(test.key) {
+			t.Errorf("Expected to find '%s' in skip list", test.key)
+		}
+
+		pair, err := s.Get(test.key)
+		if err != nil {
+			t.Fatalf("Expected KVPair, but got %v", err)
+		}
+		value, err := pair.Value()
+		if err != nil || !bytes.Equal(value, test.value) {
+			t.Errorf("Expected '%s' value, but got '%s' (%v)", test.value, value, err)
+		}
+	}
+
+	for _, key := range []string{"deviceID456", "userRole", "a", "zzz"} {
+		if s.Search([]byte(key)) {
+			t.Errorf("Expected not to find '%s' in skip list", key)
+		}
+		if _, err := s.Get([]byte(key)); !stderrors.Is(err, errors.ErrKeyNotFound) {
+			t.Errorf("Expected '%v' error for '%s', got %v", errors.ErrKeyNotFound, key, err)
+		}
+	}
+}
+
+func TestSkipList_UpdateAndGet(t *testing.T) {
+	s := New(1 << 20)
+	for _, test := range kvpairs {
+		s.Insert(kv.NewKVPair(test.key, test.value, test.ttl))
+	}
+	for _, test := range kvpairs {
+		s.Insert(kv.NewKVPair(test.key, append([]byte("new-"), test.value...), 0))
+	}
+
+	for _, test := range kvpairs {
+		pair, err := s.Get(test.key)
+		if err != nil {
+			t.Fatalf("Expected KVPair, but got %v", err)
+		}
+		value, _ := pair.Value()
+		if want := append([]byte("new-"), test.value...); !bytes.Equal(value, want) {
+			t.Errorf("Expected updated '%s' value, but got '%s'", want, value)
+		}
+	}
+
+	if n := len(s.InOrder()); n != len(kvpairs) {
+		t.Errorf("Expected %d pairs after update, got %d", len(kvpairs), n)
+	}
+}
+
+func TestSkipList_InOrder(t *testing.T) {
+	s := New(1 << 20)
+	for _, test := range kvpairs {
+		s.Insert(kv.NewKVPair(test.key, test.value, test.ttl))
+	}
+
+	pairs := s.InOrder()
+	if len(pairs) != len(kvpairs) {
+		t.Fatalf("Expected %d pairs, got %d", len(kvpairs), len(pairs))
+	}
+	for i := 1; i < len(pairs); i++ {
+		prev, _ := pairs[i-1].Key()
+		cur, _ := pairs[i].Key()
+		if bytes.Compare(prev, cur) >= 0 {
+			t.Errorf("Expected '%s' to sort before '%s'", prev, cur)
+		}
+	}
+}
+
+func TestSkipList_Expired(t *testing.T) {
+	s := New(1 << 20)
+	s.Insert(kv.NewKVPair([]byte("shortLived"), []byte("value"), time.Millisecond))
+	time.Sleep(time.Millisecond * 5)
+
+	if s.Search([]byte("shortLived")) {
+		t.Errorf("Expected expired key not to be found")
+	}
+	if _, err := s.Get([]byte("shortLived")); !stderrors.Is(err, errors.ErrKeyExpired) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyExpired, err)
+	}
+	if len(s.InOrder()) != 0 {
+		t.Errorf("Expected expired key to be skipped by InOrder")
+	}
+}
+
+func TestSkipList_Delete(t *testing.T) {
+	s := New(1 << 20)
+	for _, test := range kvpairs {
+		s.Insert(kv.NewKVPair(test.key, test.value, test.ttl))
+	}
+
+	for i, test := range kvpairs {
+		if i%2 == 0 {
+			if err := s.Delete(test.key); err != nil {
+				t.Fatalf("Expected delete to succeed, got %v", err)
+			}
+		}
+	}
+
+	for i, test := range kvpairs {
+		if found := s.Search(test.key); found != (i%2 != 0) {
+			t.Errorf("Expected Search('%s') to be %v after delete", test.key, !found)
+		}
+	}
+	if n := len(s.InOrder()); n != len(kvpairs)/2 {
+		t.Errorf("Expected %d pairs after delete, got %d", len(kvpairs)/2, n)
+	}
+
+	// A deleted key can be inserted again
+	s.Insert(kv.NewKVPair(kvpairs[0].key, []byte("back"), 0))
+	if !s.Search(kvpairs[0].key) {
+		t.Errorf("Expected to find '%s' after re-insert", kvpairs[0].key)
+	}
+}
+
+func TestSkipList_SizeLimits(t *testing.T) {
+	s := New(1 << 20)
+	s.MaxKeySize = 8
+	size := s.ApproximateSize()
+
+	err := s.Insert(kv.NewKVPair([]byte("userID123"), []byte("John Doe"), 0))
+	if !stderrors.Is(err, errors.ErrKeyTooLarge) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyTooLarge, err)
+	}
+	if err := s.Delete(nil); !stderrors.Is(err, errors.ErrKeyRequired) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyRequired, err)
+	}
+	if s.ApproximateSize() != size {
+		t.Errorf("Expected rejected writes not to allocate, got %d bytes", s.ApproximateSize()-size)
+	}
+}
+
+func TestSkipList_ConcurrentInsertAndSearch(t *testing.T) {
+	const (
+		writers = 8
+		keys    = 1000
+	)
+
+	s := New(1 << 20)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				key := []byte(fmt.Sprintf("key-%04d", i))
+				value := []byte(fmt.Sprintf("value-%d-%d", w, i))
+				if err := s.Insert(kv.NewKVPair(key, value, 0)); err != nil {
+					t.Errorf("Expected insert to succeed, got %v", err)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				s.Search([]byte(fmt.Sprintf("key-%04d", i)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	pairs := s.InOrder()
+	if len(pairs) != keys {
+		t.Fatalf("Expected %d distinct keys, got %d", keys, len(pairs))
+	}
+	for i, pair := range pairs {
+		key, _ := pair.Key()
+		if want := fmt.Sprintf("key-%04d", i); string(key) != want {
+			t.Errorf("Expected key '%s' at position %d, got '%s'", want, i, key)
+		}
+	}
+}
+
+func TestSkipList_ArenaFull(t *testing.T) {
+	s := New(1 << 12)
+
+	var err error
+	inserted := 0
+	for err == nil {
+		key := []byte(fmt.Sprintf("key-%04d", inserted))
+		if err = s.Insert(kv.NewKVPair(key, []byte("value"), 0)); err == nil {
+			inserted++
+		}
+	}
+
+	if !stderrors.Is(err, errors.ErrMemtableFull) {
+		t.Fatalf("Expected '%v' error, got %v", errors.ErrMemtableFull, err)
+	}
+	if inserted == 0 {
+		t.Fatalf("Expected some inserts to fit in the arena")
+	}
+	if size := s.ApproximateSize(); size > 1<<12 {
+		t.Errorf("Expected size to stay within the arena, got %d bytes", size)
+	}
+
+	// Everything that fit is still readable
+	if n := len(s.InOrder()); n != inserted {
+		t.Errorf("Expected %d pairs, got %d", inserted, n)
+	}
+	for i := 0; i < inserted; i++ {
+		if !s.Search([]byte(fmt.Sprintf("key-%04d", i))) {
+			t.Errorf("Expected to find 'key-%04d'", i)
+		}
+	}
+}
+
+func TestSkipList_ApproximateSize(t *testing.T) {
+	s := New(1 << 20)
+	before := s.ApproximateSize()
+
+	pair := kv.NewKVPair([]byte("userID123"), []byte("John Doe"), 0)
+	s.Insert(pair)
+	grown := s.ApproximateSize() - before
+
+	// A new key costs at least a node, the key and an entry holding the value
+	if min := int64(pair.Size() + entryHeaderSize); grown < min {
+		t.Errorf("Expected insert to account for at least %d bytes, got %d", min, grown)
+	}
+
+	// An update only costs a new entry
+	before = s.ApproximateSize()
+	s.Insert(kv.NewKVPair([]byte("userID123"), []byte("Jane Doe"), 0))
+	if grown := s.ApproximateSize() - before; grown != int64(len("Jane Doe")+entryHeaderSize) {
+		t.Errorf("Expected update to account for %d bytes, got %d", len("Jane Doe")+entryHeaderSize, grown)
+	}
+}
+
+func TestSkipList_MinArenaSize(t *testing.T) {
+	for _, size := range []int{1, 64, maxNodeSize, MinArenaSize - 1} {
+		s := New(size)
+		if err := s.Insert(kv.NewKVPair([]byte("userID123"), []byte("John Doe"), 0)); err != nil {
+			t.Fatalf("Expected insert into a %d byte arena to succeed, got %v", size, err)
+		}
+		if !s.Search([]byte("userID123")) {
+			t.Errorf("Expected to find 'userID123' in a %d byte arena", size)
+		}
+	}
+}
+
+func TestSkipList_PairLargerThanArena(t *testing.T) {
+	s := New(1 << 12)
+
+	value := make([]byte, 8<<10)
+	err := s.Insert(kv.NewKVPair([]byte("userID123"), value, 0))
+	if !stderrors.Is(err, errors.ErrValueTooLarge) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrValueTooLarge, err)
+	}
+
+	key := make([]byte, 8<<10)
+	if err := s.Insert(kv.NewKVPair(key, []byte("John Doe"), 0)); !stderrors.Is(err, errors.ErrKeyTooLarge) {
+		t.Errorf("Expected '%v' error, got %v", errors.ErrKeyTooLarge, err)
+	}
+	if err := s.Delete(key); !stderrors.Is(err, errors.ErrKeyTooLarge) {
+		t.Errorf("Expected '%v' error on delete, got %v", errors.ErrKeyTooLarge, err)
+	}
+
+	// The largest value accepted still fits in the empty arena
+	value = make([]byte, s.room-pairOverhead-len("userID123"))
+	if err := s.Insert(kv.NewKVPair([]byte("userID123"), value, 0)); err != nil {
+		t.Errorf("Expected largest value to fit, got %v", err)
+	}
+	if s.Len() != 1 {
+		t.Errorf("Expected 1 key, got %d", s.Len())
+	}
+}
+
+func TestSkipList_FarExpiration(t *testing.T) {
+	s := New(1 << 20)
+	s.Insert(kv.NewKVPair([]byte("userID123"), []byte("John Doe"), time.Duration(math.MaxInt64)))
+
+	if !s.Search([]byte("userID123")) {
+		t.Fatalf("Expected far future expiration not to wrap around into the past")
+	}
+	pair, err := s.Get([]byte("userID123"))
+	if err != nil {
+		t.Fatalf("Expected KVPair, but got %v", err)
+	}
+	if pair.IsExpired() {
+		t.Errorf("Expected KVPair not to be expired")
+	}
+}
+
+func TestClampArenaSize(t *testing.T) {
+	tests := []struct {
+		size, want int
+	}{
+		{0, DefaultArenaSize},
+		{-1, DefaultArenaSize},
+		{64, MinArenaSize},
+		{1 << 20, 1 << 20},
+	}
+	for _, tt := range tests {
+		if got := clampArenaSize(tt.size); got != tt.want {
+			t.Errorf("Expected arena size %d for %d, got %d", tt.want, tt.size, got)
+		}
+	}
+
+	// The largest sizes must not overflow newArena, even where int is 32 bits
+	for _, size := range []int{math.MaxInt32, math.MaxInt} {
+		got := clampArenaSize(size)
+		if int64(got) > MaxArenaSize || got > math.MaxInt-maxNodeSize-7 {
+			t.Errorf("Expected arena size %d to be capped, got %d", size, got)
+		}
+	}
+}